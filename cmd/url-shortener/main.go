@@ -2,19 +2,49 @@ package main
 
 import (
 	"URL-Shortener/internal/config"
+	"URL-Shortener/internal/http-server/handlers/alias/availability"
+	"URL-Shortener/internal/http-server/handlers/alias/suggest"
+	domdel "URL-Shortener/internal/http-server/handlers/domain/delete"
+	domlist "URL-Shortener/internal/http-server/handlers/domain/list"
+	"URL-Shortener/internal/http-server/handlers/domain/register"
+	"URL-Shortener/internal/http-server/handlers/golinks"
 	del "URL-Shortener/internal/http-server/handlers/url/delete"
 	"URL-Shortener/internal/http-server/handlers/url/get"
+	"URL-Shortener/internal/http-server/handlers/url/interstitial"
+	"URL-Shortener/internal/http-server/handlers/url/list"
 	"URL-Shortener/internal/http-server/handlers/url/redirect"
 	"URL-Shortener/internal/http-server/handlers/url/save"
+	"URL-Shortener/internal/http-server/handlers/url/schedule"
+	"URL-Shortener/internal/http-server/handlers/url/search"
+	"URL-Shortener/internal/http-server/handlers/url/targets"
+	"URL-Shortener/internal/http-server/handlers/url/update"
+	"URL-Shortener/internal/http-server/handlers/wellknown"
+	wscreate "URL-Shortener/internal/http-server/handlers/workspace/create"
+	wsget "URL-Shortener/internal/http-server/handlers/workspace/get"
+	"URL-Shortener/internal/http-server/handlers/workspace/key"
+	"URL-Shortener/internal/http-server/handlers/workspace/member/add"
+	"URL-Shortener/internal/http-server/handlers/workspace/member/remove"
+	wsupdate "URL-Shortener/internal/http-server/handlers/workspace/update"
 	logger "URL-Shortener/internal/http-server/middleware"
+	"URL-Shortener/internal/http-server/middleware/admin"
+	"URL-Shortener/internal/http-server/middleware/forwarded"
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	"URL-Shortener/internal/lib/geoip"
+	"URL-Shortener/internal/lib/linkpass"
 	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/random"
+	"URL-Shortener/internal/lib/ratelimit"
+	"URL-Shortener/internal/lib/shorturl"
 	"URL-Shortener/internal/storage/postgres"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -48,7 +78,66 @@ func main() {
 
 	go monitorPoolStats(storage, log)
 
-	router := setupRouter(log, storage, cfg)
+	if cfg.CaseInsensitiveAliases {
+		foldAliases(log, storage)
+	}
+
+	reservedWords := cfg.AliasPolicy.ReservedWords
+	if len(cfg.ReservedAliases) > 0 {
+		log.Warn("app.reserved_aliases is deprecated, use app.alias_policy.reserved_words")
+		reservedWords = append(reservedWords, cfg.ReservedAliases...)
+	}
+	if cfg.GoLinks.Enabled {
+		reservedWords = append(reservedWords, "opensearch")
+	}
+
+	policy, err := alias.NewPolicy(alias.PolicyConfig{
+		MinLength:     cfg.AliasPolicy.MinLength,
+		MaxLength:     cfg.AliasPolicy.MaxLength,
+		Charset:       cfg.AliasPolicy.Charset,
+		Unicode:       cfg.AliasPolicy.Unicode,
+		Hierarchical:  cfg.AliasPolicy.Hierarchical,
+		MaxDepth:      cfg.AliasPolicy.MaxDepth,
+		Case:          cfg.AliasPolicy.Case,
+		ReservedWords: reservedWords,
+		BlockedWords:  cfg.AliasPolicy.BlockedWords,
+	})
+	if err != nil {
+		log.Error("invalid alias policy", sl.Err(err))
+		os.Exit(1)
+	}
+	checkReservedAliases(log, storage, policy.Reserved())
+
+	gen, err := random.NewGenerator(cfg.AliasGenerator.Mode, cfg.AliasGenerator.CheckChar)
+	if err != nil {
+		log.Error("invalid alias generator", sl.Err(err))
+		os.Exit(1)
+	}
+	for _, v := range policy.Check(gen.Generate(cfg.AliasLength)) {
+		if v.Rule == "charset" {
+			log.Error("generated aliases do not match the alias policy charset", slog.String("mode", cfg.AliasGenerator.Mode))
+			os.Exit(1)
+		}
+	}
+	checkShapedAliases(log, storage, gen, cfg.AliasLength)
+
+	trusted, err := forwarded.ParseTrusted(cfg.TrustedProxies)
+	if err != nil {
+		log.Error("invalid trusted proxies", sl.Err(err))
+		os.Exit(1)
+	}
+
+	var geo *geoip.DB
+	if cfg.GeoIP.Database != "" {
+		geo, err = geoip.Open(log, cfg.GeoIP.Database, cfg.GeoIP.ReloadInterval)
+		if err != nil {
+			log.Error("error loading geoip database", sl.Err(err))
+			os.Exit(1)
+		}
+		defer geo.Close()
+	}
+
+	router := setupRouter(log, storage, cfg, policy, gen, trusted, geo)
 
 	server := setupServer(cfg, router)
 
@@ -98,10 +187,98 @@ func monitorPoolStats(storage *postgres.Storage, log *slog.Logger) {
 	}
 }
 
-func setupRouter(log *slog.Logger, storage *postgres.Storage, cfg *config.Config) *chi.Mux {
+// foldAliases migrates stored aliases to case-insensitive mode. Aliases
+// that only differ in case cannot be merged automatically, so they are
+// reported and the service refuses to start until they are resolved.
+func foldAliases(log *slog.Logger, storage *postgres.Storage) {
+	collisions, err := storage.CaseCollisions(0)
+	if err != nil {
+		log.Error("failed to check case collisions", sl.Err(err))
+		os.Exit(1)
+	}
+	if len(collisions) > 0 {
+		for _, c := range collisions {
+			log.Error("alias collides with another one when case is ignored",
+				slog.String("alias", c.Alias),
+				slog.String("domain", c.Domain),
+				slog.Int64("workspace_id", c.WorkspaceID),
+			)
+		}
+		log.Error("cannot enable case-insensitive aliases", slog.Int("collisions", len(collisions)))
+		os.Exit(1)
+	}
+
+	if err := storage.FoldAliases(); err != nil {
+		log.Error("failed to fold aliases", sl.Err(err))
+		os.Exit(1)
+	}
+}
+
+// checkReservedAliases warns about links created before their alias
+// became reserved; they are shadowed by system routes and unreachable.
+func checkReservedAliases(log *slog.Logger, storage *postgres.Storage, reserved alias.Reserved) {
+	collisions, err := storage.FindAliases(reserved.Words())
+	if err != nil {
+		log.Error("failed to check aliases against reserved words", sl.Err(err))
+		return
+	}
+
+	for _, c := range collisions {
+		log.Warn("existing alias collides with a reserved word",
+			slog.String("alias", c.Alias),
+			slog.String("domain", c.Domain),
+			slog.Int64("workspace_id", c.WorkspaceID),
+		)
+	}
+}
+
+// checkShapedAliases warns about links whose alias looks generated but
+// lacks a valid check character; redirects reject them as mistyped.
+func checkShapedAliases(log *slog.Logger, storage *postgres.Storage, gen *random.Generator, aliasLength int) {
+	if !gen.HasCheckChar() {
+		return
+	}
+
+	shadowed, err := storage.FilterAliases(func(a string, length int, caseInsensitive bool) bool {
+		if length == 0 {
+			length = aliasLength
+		}
+		_, ok := gen.Admit(a, length, caseInsensitive)
+		return !ok
+	})
+	if err != nil {
+		log.Error("failed to check aliases against check characters", sl.Err(err))
+		return
+	}
+
+	for _, c := range shadowed {
+		log.Warn("existing alias looks generated but has an invalid check character",
+			slog.String("alias", c.Alias),
+			slog.String("domain", c.Domain),
+			slog.Int64("workspace_id", c.WorkspaceID),
+		)
+	}
+}
+
+func setupRouter(log *slog.Logger, storage *postgres.Storage, cfg *config.Config, policy *alias.Policy, gen *random.Generator, trusted []*net.IPNet, geo *geoip.DB) *chi.Mux {
+	shortURL := shorturl.New(cfg.BaseURL)
+
+	placeholders := redirect.Placeholders{
+		Reserved:     cfg.Placeholders.Reserved,
+		NotYetActive: cfg.Placeholders.NotYetActive,
+		Ended:        cfg.Placeholders.Ended,
+		UsedUp:       cfg.Placeholders.UsedUp,
+	}
+	notFound := golinks.NotFound(log, storage, shortURL, cfg.GoLinks.Keyword, cfg.GoLinks.Enabled)
+	gate := redirect.PasswordGate{
+		Signer:  linkpass.NewSigner(cookieSecret(log, cfg.LinkPasswords.CookieSecret), cfg.LinkPasswords.CookieTTL),
+		Limiter: ratelimit.New(cfg.LinkPasswords.MaxFailures, cfg.LinkPasswords.FailureWindow),
+	}
+
 	router := chi.NewRouter()
 	//mw
 	router.Use(middleware.RequestID)
+	router.Use(forwarded.New(log, trusted))
 	router.Use(middleware.Logger)
 	//mw-l
 	router.Use(logger.New(log))
@@ -110,15 +287,97 @@ func setupRouter(log *slog.Logger, storage *postgres.Storage, cfg *config.Config
 	router.Use(middleware.URLFormat)
 
 	router.Route("/api/v1", func(r chi.Router) {
-		r.Post("/url", save.New(log, storage, cfg.AliasLength, cfg.MaxAttempts))
-		r.Get("/url/{alias}", get.New(log, storage))
-		r.Delete("/url/{alias}", del.New(log, storage))
-		r.Get("/{alias}", redirect.New(log, storage))
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(admin.New(log, cfg.AdminToken))
+
+			r.Post("/workspaces", wscreate.New(log, storage))
+			r.Get("/workspaces/{slug}", wsget.New(log, storage))
+			r.Put("/workspaces/{slug}/settings", wsupdate.New(log, storage))
+			r.Post("/workspaces/{slug}/keys", key.New(log, storage))
+			r.Post("/workspaces/{slug}/members", add.New(log, storage))
+			r.Delete("/workspaces/{slug}/members", remove.New(log, storage))
+			r.Put("/workspaces/{slug}/interstitial/*", interstitial.New(log, storage))
+
+			r.Post("/domains", register.New(log, storage))
+			r.Get("/domains", domlist.New(log, storage))
+			r.Delete("/domains", domdel.New(log, storage))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(workspace.Authenticated(log, storage))
+
+			r.Post("/url", save.New(log, storage, policy, gen, shortURL, cfg.AliasLength, cfg.MaxAttempts))
+			r.Get("/url/*", get.New(log, storage, shortURL))
+			r.Patch("/url/*", update.New(log, storage, shortURL))
+			r.Delete("/url/*", del.New(log, storage))
+			r.Put("/schedule/*", schedule.New(log, storage))
+			r.Put("/targets/*", targets.New(log, storage))
+			r.Get("/urls", list.New(log, storage, shortURL))
+			r.Get("/urls/search", search.New(log, storage, shortURL))
+			r.Get("/aliases/suggest", suggest.New(log, storage, shortURL))
+			r.Get("/aliases/{alias}/availability", availability.New(log, storage, policy, gen, cfg.AliasLength))
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(workspace.New(log, storage))
+
+			// Kept so that links shared before redirects moved to the
+			// site root keep working.
+			redirectHandler := redirect.New(log, storage, gen, cfg.AliasLength, notFound, placeholders, geo, gate)
+			r.Get("/{alias}", redirectHandler)
+			r.Post("/{alias}", redirectHandler)
+		})
+	})
+
+	appLinks := wellknown.AppLinks{
+		AppleAppIDs:         cfg.AppLinks.AppleAppIDs,
+		ApplePaths:          cfg.AppLinks.ApplePaths,
+		AndroidPackage:      cfg.AppLinks.AndroidPackage,
+		AndroidFingerprints: cfg.AppLinks.AndroidFingerprints,
+	}
+	switch {
+	case len(appLinks.AppleAppIDs) > 0 && len(appLinks.ApplePaths) == 0:
+		log.Warn("app_links.apple_paths is empty, not serving apple-app-site-association")
+	case len(appLinks.AppleAppIDs) > 0:
+		router.Get("/.well-known/apple-app-site-association", wellknown.AppleAppSiteAssociation(log, appLinks))
+	}
+	if appLinks.AndroidPackage != "" {
+		// URLFormat strips the extension of /.well-known/assetlinks.json.
+		router.Get("/.well-known/assetlinks", wellknown.AssetLinks(log, appLinks))
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(workspace.New(log, storage))
+
+		if cfg.GoLinks.Enabled {
+			// URLFormat strips the extension of /opensearch.xml.
+			r.Get("/opensearch", golinks.OpenSearch(log, shortURL, cfg.GoLinks.Keyword))
+		}
+		redirectHandler := redirect.New(log, storage, gen, cfg.AliasLength, notFound, placeholders, geo, gate)
+		r.Get("/*", redirectHandler)
+		// Password forms post back to the link.
+		r.Post("/*", redirectHandler)
 	})
 
 	return router
 }
 
+// cookieSecret returns the configured secret for signing cookies or, if
+// there is none, a random one that only lasts until the next restart.
+func cookieSecret(log *slog.Logger, secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+
+	log.Warn("link_passwords.cookie_secret is not set, unlocked links are forgotten on restart")
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Error("failed to generate cookie secret", sl.Err(err))
+		os.Exit(1)
+	}
+	return b
+}
+
 func setupServer(cfg *config.Config, r *chi.Mux) *http.Server {
 	server := &http.Server{
 		Addr:         cfg.Addr,
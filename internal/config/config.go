@@ -17,8 +17,124 @@ type Config struct {
 }
 
 type App struct {
-	AliasLength int `yaml:"alias_length" env-required:"true"`
-	MaxAttempts int `yaml:"max_attempts" env-required:"true"`
+	AliasLength int    `yaml:"alias_length" env-required:"true"`
+	MaxAttempts int    `yaml:"max_attempts" env-required:"true"`
+	AdminToken  string `yaml:"admin_token" env:"ADMIN_TOKEN"`
+	// CaseInsensitiveAliases folds the case of aliases in every workspace.
+	// Workspaces can also opt in individually.
+	CaseInsensitiveAliases bool `yaml:"case_insensitive_aliases"`
+	// AliasTombstoneTTL keeps deleted aliases from being reused for this
+	// long, so that old links cannot be taken over. Zero disables it.
+	AliasTombstoneTTL time.Duration `yaml:"alias_tombstone_ttl" env-default:"0s"`
+	AliasPolicy       `yaml:"alias_policy"`
+	// ReservedAliases is the former name of alias_policy.reserved_words
+	// and is still added to them.
+	//
+	// Deprecated: use AliasPolicy.ReservedWords.
+	ReservedAliases []string `yaml:"reserved_aliases"`
+	AliasGenerator  `yaml:"alias_generator"`
+	// BaseURL is the public URL short links are built from, including any
+	// path prefix added by a reverse proxy, e.g. "https://sho.rt/s".
+	BaseURL       string `yaml:"base_url" env:"BASE_URL"`
+	Placeholders  `yaml:"placeholders"`
+	GoLinks       `yaml:"go_links"`
+	GeoIP         `yaml:"geoip"`
+	AppLinks      `yaml:"app_links"`
+	LinkPasswords `yaml:"link_passwords"`
+	// TrustedProxies are the CIDR ranges (or single addresses) of reverse
+	// proxies whose Forwarded and X-Forwarded-For/-Proto/-Host headers
+	// are believed for the client address, scheme and host.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// AppLinks are published under /.well-known on every short domain so
+// that installed apps open short links directly.
+type AppLinks struct {
+	// AppleAppIDs are "<team id>.<bundle id>" identifiers; empty
+	// disables apple-app-site-association.
+	AppleAppIDs []string `yaml:"apple_app_ids"`
+	// ApplePaths are the paths the app handles; required with
+	// AppleAppIDs.
+	ApplePaths []string `yaml:"apple_paths"`
+	// AndroidPackage is the application id; empty disables
+	// assetlinks.json.
+	AndroidPackage      string   `yaml:"android_package"`
+	AndroidFingerprints []string `yaml:"android_sha256_fingerprints"`
+}
+
+// LinkPasswords configures password-protected links.
+type LinkPasswords struct {
+	// CookieSecret signs the cookies that remember visitors who gave a
+	// link's password. If empty, a random one is generated at startup and
+	// passwords must be given again after a restart.
+	CookieSecret string        `yaml:"cookie_secret" env:"LINK_COOKIE_SECRET"`
+	CookieTTL    time.Duration `yaml:"cookie_ttl" env-default:"1h"`
+	// MaxFailures wrong passwords per link and client are allowed within
+	// FailureWindow; further attempts are refused until it ends.
+	MaxFailures   int           `yaml:"max_failures" env-default:"5"`
+	FailureWindow time.Duration `yaml:"failure_window" env-default:"15m"`
+}
+
+// GeoIP is a local MaxMind (GeoIP2 or GeoLite2) database used by
+// location targeting rules. Without one those rules never match.
+type GeoIP struct {
+	Database string `yaml:"database" env:"GEOIP_DATABASE"`
+	// ReloadInterval is how often the file is checked for changes; zero
+	// disables reloading.
+	ReloadInterval time.Duration `yaml:"reload_interval" env-default:"1m"`
+}
+
+// Placeholders are the pages links redirect to while they cannot be
+// followed; "{alias}" is replaced with the alias. An empty page answers
+// with a JSON error instead.
+type Placeholders struct {
+	// Reserved is for aliases claimed without a destination yet.
+	Reserved string `yaml:"reserved"`
+	// NotYetActive is for links whose activation window has not begun.
+	NotYetActive string `yaml:"not_yet_active"`
+	// Ended is for links whose activation window is over.
+	Ended string `yaml:"ended"`
+	// UsedUp is for links that have no clicks left.
+	UsedUp string `yaml:"used_up"`
+}
+
+// GoLinks turns the service into a company go/ link service: unknown
+// aliases get an HTML page with suggestions and a create form instead of
+// a JSON error, and browsers can add it as a search keyword.
+type GoLinks struct {
+	Enabled bool `yaml:"enabled"`
+	// Keyword is the name users type in the address bar, e.g. "go".
+	Keyword string `yaml:"keyword" env-default:"go"`
+}
+
+type AliasGenerator struct {
+	// Mode is one of "letters", "alphanumeric", "crockford" or "words".
+	Mode string `yaml:"mode" env-default:"letters"`
+	// CheckChar appends a check character to generated aliases, after a
+	// '-' unless in words mode, so that mistyped ones are rejected
+	// without a storage lookup.
+	CheckChar bool `yaml:"check_char"`
+}
+
+type AliasPolicy struct {
+	MinLength int    `yaml:"min_length" env-default:"1"`
+	MaxLength int    `yaml:"max_length" env-default:"64"`
+	Charset   string `yaml:"charset" env-default:"^[a-zA-Z0-9_-]+$"`
+	// Unicode admits non-ASCII aliases (Cyrillic, CJK, emoji...). The
+	// default charset is widened accordingly unless Charset is customised.
+	Unicode bool `yaml:"unicode"`
+	// Hierarchical admits '/'-separated aliases such as "docs/onboarding".
+	Hierarchical bool `yaml:"hierarchical"`
+	MaxDepth     int  `yaml:"max_depth" env-default:"4"`
+	// Case is one of "mixed", "lower" (fold to lower case) or
+	// "strict-lower" (reject upper case).
+	Case string `yaml:"case" env-default:"mixed"`
+	// ReservedWords extends the built-in list of words that cannot be
+	// used as aliases because they collide with system routes.
+	ReservedWords []string `yaml:"reserved_words"`
+	// BlockedWords are rejected anywhere inside an alias, after folding
+	// case, separators and leetspeak.
+	BlockedWords []string `yaml:"blocked_words"`
 }
 
 type HttpServer struct {
@@ -0,0 +1,144 @@
+package availability
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/random"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+const maxAlternatives = 5
+
+const (
+	ReasonInvalid    = "invalid"
+	ReasonTaken      = "taken"
+	ReasonTombstoned = "tombstoned"
+)
+
+type AliasChecker interface {
+	TakenAliases(scope storage.Scope, aliases []string) (map[string]error, error)
+}
+
+type Response struct {
+	resp.Response
+	// Alias is the requested alias as it would be stored.
+	Alias     string `json:"alias"`
+	Available bool   `json:"available"`
+	// Reason is one of the Reason* constants when Available is false.
+	Reason       string            `json:"reason,omitempty"`
+	Violations   []resp.FieldError `json:"violations,omitempty"`
+	Alternatives []string          `json:"alternatives,omitempty"`
+}
+
+// New reports whether a custom alias can be used: it must pass the alias
+// policy and be neither taken nor recently deleted. Otherwise close
+// alternatives that are available are offered.
+func New(log *slog.Logger, checker AliasChecker, policy *alias.Policy, gen *random.Generator, aliasLength int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.alias.availability.New"
+		log = log.With(slog.String("operation", op))
+
+		requested, err := alias.FromRequest(r)
+		if err != nil || requested == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("alias is required"))
+			return
+		}
+
+		scope := workspace.Scope(r)
+		length := aliasLength
+		if ws := workspace.FromContext(r.Context()); ws.AliasLength > 0 {
+			length = ws.AliasLength
+		}
+
+		// admit applies what save.New would do to a custom alias.
+		admit := func(a string) (string, []alias.Violation) {
+			a = policy.Normalize(a)
+			if violations := policy.Check(a); len(violations) > 0 {
+				return a, violations
+			}
+			admitted, ok := gen.Admit(a, length, scope.CaseInsensitive)
+			if !ok {
+				return a, []alias.Violation{alias.CheckCharViolation}
+			}
+			return scope.Key(admitted), nil
+		}
+
+		key, violations := admit(requested)
+		response := Response{Response: resp.Ok(), Alias: key}
+
+		if len(violations) > 0 {
+			response.Reason = ReasonInvalid
+			response.Violations = resp.AliasPolicyError("alias", violations).Details
+		} else {
+			taken, err := checker.TakenAliases(scope, []string{key})
+			if err != nil {
+				log.Error("failed to check alias", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("failed to check alias"))
+				return
+			}
+			switch reason, ok := taken[key]; {
+			case !ok:
+				response.Available = true
+			case errors.Is(reason, storage.ErrAliasTombstoned):
+				response.Reason = ReasonTombstoned
+			default:
+				response.Reason = ReasonTaken
+			}
+		}
+
+		if !response.Available {
+			alternatives, err := available(checker, scope, admit, key)
+			if err != nil {
+				// The verdict stands without alternatives.
+				log.Error("failed to check alternatives", sl.Err(err))
+			}
+			response.Alternatives = alternatives
+		}
+
+		render.JSON(w, r, response)
+	}
+}
+
+// available returns up to maxAlternatives variations of key that pass
+// admit and are free in scope.
+func available(checker AliasChecker, scope storage.Scope, admit func(string) (string, []alias.Violation), key string) ([]string, error) {
+	seen := map[string]bool{key: true}
+	var candidates []string
+	for _, a := range alias.Alternatives(key) {
+		a, violations := admit(a)
+		if len(violations) > 0 || seen[a] {
+			continue
+		}
+		seen[a] = true
+		candidates = append(candidates, a)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	taken, err := checker.TakenAliases(scope, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	var alternatives []string
+	for _, a := range candidates {
+		if _, ok := taken[a]; ok {
+			continue
+		}
+		alternatives = append(alternatives, a)
+		if len(alternatives) == maxAlternatives {
+			break
+		}
+	}
+	return alternatives, nil
+}
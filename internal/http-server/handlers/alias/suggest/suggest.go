@@ -0,0 +1,65 @@
+package suggest
+
+import (
+	"URL-Shortener/internal/http-server/handlers/golinks"
+	"URL-Shortener/internal/lib/alias"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/shorturl"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultLimit = 10
+	maxLimit     = 50
+)
+
+type Response struct {
+	resp.Response
+	Suggestions []golinks.Suggestion `json:"suggestions"`
+}
+
+// New autocompletes aliases: it returns the existing aliases that start
+// with q or are a few typos away from it.
+func New(log *slog.Logger, suggester golinks.AliasSuggester, shortURL *shorturl.Builder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.alias.suggest.New"
+		log = log.With(slog.String("operation", op))
+
+		query, err := alias.FromPath(r.URL.Query().Get("q"))
+		if err != nil || query == "" {
+			log.Info("empty suggest query")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("query parameter q is required"))
+			return
+		}
+
+		limit := defaultLimit
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				log.Info("invalid suggest limit", slog.String("limit", raw))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+			limit = min(n, maxLimit)
+		}
+
+		suggestions, err := golinks.Suggest(suggester, shortURL, r, query, limit, false)
+		if err != nil {
+			log.Error("failed to suggest aliases", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to suggest aliases"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:    resp.Ok(),
+			Suggestions: suggestions,
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package delete
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type DomainDelete interface {
+	DeleteDomain(host string) error
+}
+
+type Response struct {
+	resp.Response
+}
+
+func New(log *slog.Logger, domainDelete DomainDelete) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.domain.delete.New"
+		log = log.With(slog.String("operation", op))
+
+		// The host travels in the query string: URLFormat would otherwise
+		// treat its TLD as a response format extension.
+		host := workspace.NormalizeHost(r.URL.Query().Get("host"))
+		if host == "" {
+			log.Info("missing host")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("query parameter host is required"))
+			return
+		}
+
+		if err := domainDelete.DeleteDomain(host); err != nil {
+			if errors.Is(err, storage.ErrDomainNotFound) {
+				log.Info("domain not found", slog.String("host", host))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("domain not found"))
+				return
+			}
+			log.Error("failed to delete domain", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to delete domain"))
+			return
+		}
+
+		log.Info("domain deleted", slog.String("host", host))
+
+		render.JSON(w, r, Response{Response: resp.Ok()})
+	}
+}
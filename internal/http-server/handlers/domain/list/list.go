@@ -0,0 +1,56 @@
+package list
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type DomainLister interface {
+	ListDomains() ([]storage.Domain, error)
+}
+
+type Domain struct {
+	Host        string `json:"host"`
+	WorkspaceID int64  `json:"workspace_id"`
+	FallbackURL string `json:"fallback_url,omitempty"`
+	BaseURL     string `json:"base_url,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Domains []Domain `json:"domains"`
+}
+
+func New(log *slog.Logger, lister DomainLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.domain.list.New"
+		log = log.With(slog.String("operation", op))
+
+		domains, err := lister.ListDomains()
+		if err != nil {
+			log.Error("failed to list domains", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to list domains"))
+			return
+		}
+
+		out := make([]Domain, 0, len(domains))
+		for _, d := range domains {
+			out = append(out, Domain{
+				Host:        d.Host,
+				WorkspaceID: d.WorkspaceID,
+				FallbackURL: d.FallbackURL,
+				BaseURL:     d.BaseURL,
+			})
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.Ok(),
+			Domains:  out,
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package register
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+)
+
+type DomainRegistrar interface {
+	GetWorkspace(slug string) (storage.Workspace, error)
+	SaveDomain(d storage.Domain) error
+}
+
+type Request struct {
+	Host        string `json:"host" validate:"required,hostname_rfc1123"`
+	Workspace   string `json:"workspace" validate:"required"`
+	FallbackURL string `json:"fallback_url,omitempty" validate:"omitempty,url"`
+	BaseURL     string `json:"base_url,omitempty" validate:"omitempty,url"`
+}
+
+type Response struct {
+	resp.Response
+	Host        string `json:"host,omitempty"`
+	Workspace   string `json:"workspace,omitempty"`
+	FallbackURL string `json:"fallback_url,omitempty"`
+	BaseURL     string `json:"base_url,omitempty"`
+}
+
+// New registers a short domain for a workspace, or updates the fallback
+// URL of a domain the workspace already owns.
+func New(log *slog.Logger, registrar DomainRegistrar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.domain.register.New"
+		log = log.With(slog.String("operation", op))
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			errors.As(err, &validationErrors)
+
+			log.Error("failed to validate request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validationErrors))
+			return
+		}
+
+		ws, err := registrar.GetWorkspace(req.Workspace)
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceNotFound) {
+				log.Info("workspace not found", slog.String("slug", req.Workspace))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("workspace not found"))
+				return
+			}
+			log.Error("failed to get workspace", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to register domain"))
+			return
+		}
+
+		host := workspace.NormalizeHost(req.Host)
+
+		err = registrar.SaveDomain(storage.Domain{
+			Host:        host,
+			WorkspaceID: ws.ID,
+			FallbackURL: req.FallbackURL,
+			BaseURL:     req.BaseURL,
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrDomainExists) {
+				log.Info("domain owned by another workspace", slog.String("host", host))
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, resp.Error("domain already registered to another workspace"))
+				return
+			}
+			log.Error("failed to register domain", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to register domain"))
+			return
+		}
+
+		log.Info("domain registered", slog.String("host", host), slog.String("workspace", ws.Slug))
+
+		render.JSON(w, r, Response{
+			Response:    resp.Ok(),
+			Host:        host,
+			Workspace:   ws.Slug,
+			FallbackURL: req.FallbackURL,
+			BaseURL:     req.BaseURL,
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package golinks
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/shorturl"
+	"URL-Shortener/internal/storage"
+	"github.com/go-chi/render"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// maxCandidates bounds how many similar aliases storage returns for
+// reranking by edit distance.
+const maxCandidates = 50
+
+const maxSuggestions = 8
+
+type AliasSuggester interface {
+	SimilarAliases(scope storage.Scope, query string, limit int, public bool) ([]string, error)
+}
+
+type Suggestion struct {
+	Alias    string `json:"alias"`
+	ShortURL string `json:"short_url"`
+}
+
+type Response struct {
+	resp.Response
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+type notFoundPage struct {
+	// Name is how the missing link is displayed, e.g. "go/foo".
+	Name        string
+	Keyword     string
+	Alias       string
+	Suggestions []Suggestion
+	Create      bool
+}
+
+var notFoundTmpl = template.Must(template.New("notfound").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} does not exist</title>
+{{if .Create}}<link rel="search" type="application/opensearchdescription+xml" title="{{.Keyword}}/" href="/opensearch.xml">{{end}}
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 3em auto; padding: 0 1em; }
+input[type=url], input[type=password] { width: 100%; box-sizing: border-box; padding: .4em; }
+#error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>{{.Name}} does not exist{{if .Create}} yet{{end}}</h1>
+{{if .Suggestions}}
+<p>Did you mean:</p>
+<ul>
+{{range .Suggestions}}<li><a href="{{.ShortURL}}">{{if $.Create}}{{$.Keyword}}/{{.Alias}}{{else}}{{.ShortURL}}{{end}}</a></li>
+{{end}}</ul>
+{{end}}
+{{if .Create}}
+<h2>Create it</h2>
+<form id="create">
+<label for="url">{{.Name}} should point to</label>
+<input type="url" id="url" name="url" placeholder="https://" required autofocus>
+<label for="key">API key</label>
+<input type="password" id="key" name="key" required autocomplete="off">
+<p><button type="submit">Create link</button> <span id="error"></span></p>
+</form>
+<script>
+document.getElementById("create").addEventListener("submit", async function (e) {
+	e.preventDefault();
+	const res = await fetch("/api/v1/url", {
+		method: "POST",
+		headers: {"Content-Type": "application/json", "X-API-Key": document.getElementById("key").value},
+		body: JSON.stringify({alias: {{.Alias}}, url: document.getElementById("url").value}),
+	});
+	const body = await res.json();
+	if (res.ok && body.status === "OK") {
+		location.href = body.short_url;
+	} else {
+		document.getElementById("error").textContent = body.error || "failed to create link";
+	}
+});
+</script>
+{{end}}
+</body>
+</html>
+`))
+
+// NotFound answers for an alias that does not exist with the closest
+// existing aliases: as an HTML page for browsers and as JSON for API
+// clients. In go-links mode (create) the page also has a form to create
+// the link on the spot.
+func NotFound(log *slog.Logger, suggester AliasSuggester, shortURL *shorturl.Builder, keyword string, create bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.golinks.NotFound"
+		log := log.With(slog.String("operation", op))
+
+		requested, err := alias.FromRequest(r)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid alias"))
+			return
+		}
+
+		// Anyone can ask, so links that are not meant to be found are
+		// left out.
+		suggestions, err := Suggest(suggester, shortURL, r, requested, maxSuggestions, true)
+		if err != nil {
+			// The answer is still useful without suggestions.
+			log.Error("failed to suggest aliases", sl.Err(err))
+		}
+
+		if !wantsHTML(r) {
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, Response{
+				Response:    resp.Error("url not found"),
+				Suggestions: suggestions,
+			})
+			return
+		}
+
+		page := notFoundPage{
+			Name:        shortURL.Build(r, workspace.DomainFromContext(r.Context()), requested),
+			Keyword:     keyword,
+			Alias:       requested,
+			Suggestions: suggestions,
+			Create:      create,
+		}
+		if create {
+			page.Name = keyword + "/" + requested
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		if err := notFoundTmpl.Execute(w, page); err != nil {
+			log.Error("failed to render page", sl.Err(err))
+		}
+	}
+}
+
+// Suggest returns up to n existing aliases closest to query, nearest
+// first, with their short URLs. public leaves out password-protected,
+// click-limited and reserved links.
+func Suggest(suggester AliasSuggester, shortURL *shorturl.Builder, r *http.Request, query string, n int, public bool) ([]Suggestion, error) {
+	candidates, err := suggester.SimilarAliases(workspace.Scope(r), query, maxCandidates, public)
+	if err != nil {
+		return nil, err
+	}
+
+	domain := workspace.DomainFromContext(r.Context())
+	suggestions := make([]Suggestion, 0, n)
+	for _, a := range alias.Closest(query, candidates, n) {
+		suggestions = append(suggestions, Suggestion{
+			Alias:    a,
+			ShortURL: shortURL.Build(r, domain, a),
+		})
+	}
+	return suggestions, nil
+}
+
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,43 @@
+package golinks
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/shorturl"
+	"log/slog"
+	"net/http"
+	"text/template"
+)
+
+var openSearchTmpl = template.Must(template.New("opensearch").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<OpenSearchDescription xmlns="http://a9.com/-/spec/opensearch/1.1/">
+<ShortName>{{.Keyword | html}}/</ShortName>
+<Description>{{.Keyword | html}}/ links</Description>
+<InputEncoding>UTF-8</InputEncoding>
+<Url type="text/html" method="get" template="{{.Template | html}}"/>
+</OpenSearchDescription>
+`))
+
+// OpenSearch serves an OpenSearch description so that browsers offer the
+// service as a search engine: typing "go foo" then opens go/foo.
+func OpenSearch(log *slog.Logger, shortURL *shorturl.Builder, keyword string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.golinks.OpenSearch"
+		log := log.With(slog.String("operation", op))
+
+		// Join would escape the braces of the placeholder.
+		base := shortURL.Build(r, workspace.DomainFromContext(r.Context()), "")
+
+		w.Header().Set("Content-Type", "application/opensearchdescription+xml")
+		err := openSearchTmpl.Execute(w, struct {
+			Keyword  string
+			Template string
+		}{
+			Keyword:  keyword,
+			Template: base + "{searchTerms}",
+		})
+		if err != nil {
+			log.Error("failed to render opensearch description", sl.Err(err))
+		}
+	}
+}
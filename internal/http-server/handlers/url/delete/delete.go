@@ -1,18 +1,19 @@
 package delete
 
 import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
 	resp "URL-Shortener/internal/lib/api/response"
 	"URL-Shortener/internal/lib/logger/sl"
 	"URL-Shortener/internal/storage"
 	"errors"
-	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"log/slog"
 	"net/http"
 )
 
 type URLDelete interface {
-	DeleteUrl(alias string) error
+	DeleteUrl(scope storage.Scope, alias string) error
 }
 
 type Response struct {
@@ -24,8 +25,8 @@ func New(log *slog.Logger, urlDelete URLDelete) http.HandlerFunc {
 		const op = "handlers.url.delete"
 		log = log.With(slog.String("operation", op))
 
-		alias := chi.URLParam(r, "alias")
-		if alias == "" {
+		alias, err := alias.FromRequest(r)
+		if err != nil || alias == "" {
 			log.Error("missing alias")
 
 			render.Status(r, http.StatusBadRequest)
@@ -35,7 +36,7 @@ func New(log *slog.Logger, urlDelete URLDelete) http.HandlerFunc {
 
 		}
 
-		err := urlDelete.DeleteUrl(alias)
+		err = urlDelete.DeleteUrl(workspace.Scope(r), alias)
 		if err != nil {
 			if errors.Is(err, storage.ErrUrlNotFound) {
 				log.Info("url not found for deletion")
@@ -1,42 +1,86 @@
 package get
 
 import (
+	"URL-Shortener/internal/http-server/handlers/url/targets"
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
 	resp "URL-Shortener/internal/lib/api/response"
 	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/shorturl"
 	"URL-Shortener/internal/storage"
 	"errors"
-	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"log/slog"
 	"net/http"
+	"time"
 )
 
 type URLGet interface {
-	GetUrl(alias string) (string, error)
+	GetLink(scope storage.Scope, alias string) (storage.Link, error)
 }
 
 type Response struct {
 	resp.Response
-	Url   string `json:"url,omitempty"`
-	Alias string `json:"alias,omitempty"`
+	Url      string `json:"url,omitempty"`
+	Alias    string `json:"alias,omitempty"`
+	ShortURL string `json:"short_url,omitempty"`
+	State    string `json:"state,omitempty"`
+
+	ActiveFrom  *time.Time     `json:"active_from,omitempty"`
+	ActiveUntil *time.Time     `json:"active_until,omitempty"`
+	Schedule    []Entry        `json:"schedule,omitempty"`
+	Variants    []Variant      `json:"variants,omitempty"`
+	Targets     []targets.Rule `json:"targets,omitempty"`
+	App         *App           `json:"app,omitempty"`
+	// Protected reports that the link asks for a password.
+	Protected bool `json:"protected,omitempty"`
+	// MaxClicks and ClicksLeft are set for links with a click limit.
+	MaxClicks  int  `json:"max_clicks,omitempty"`
+	ClicksLeft *int `json:"clicks_left,omitempty"`
+	// Interstitial is set when visitors see a warning page first.
+	Interstitial *Interstitial `json:"interstitial,omitempty"`
+	CreatedAt    *time.Time    `json:"created_at,omitempty"`
+}
+
+type Interstitial struct {
+	Message string `json:"message,omitempty"`
 }
 
-func New(log *slog.Logger, get URLGet) http.HandlerFunc {
+type App struct {
+	URL             string `json:"url"`
+	IOSFallback     string `json:"ios_fallback,omitempty"`
+	AndroidFallback string `json:"android_fallback,omitempty"`
+}
+
+// Variant reports the clicks each destination of a split link got.
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Clicks int64  `json:"clicks"`
+}
+
+type Entry struct {
+	EffectiveFrom time.Time `json:"effective_from"`
+	URL           string    `json:"url"`
+}
+
+func New(log *slog.Logger, get URLGet, shortURL *shorturl.Builder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.get.New"
 		log = log.With(
 			slog.String("operation", op),
 		)
 
-		alias := chi.URLParam(r, "alias")
-		if alias == "" {
+		alias, err := alias.FromRequest(r)
+		if err != nil || alias == "" {
 			log.Error("Alias is empty")
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, "Alias is required")
 			return
 		}
 
-		url, err := get.GetUrl(alias)
+		link, err := get.GetLink(workspace.Scope(r), alias)
 		if err != nil {
 
 			if errors.Is(err, storage.ErrUrlNotFound) {
@@ -52,14 +96,55 @@ func New(log *slog.Logger, get URLGet) http.HandlerFunc {
 
 			return
 		}
-		
-		responseOk(w, r, url)
+
+		responseOk(w, r, link, shortURL.Build(r, workspace.DomainFromContext(r.Context()), link.Alias))
 	}
 }
 
-func responseOk(w http.ResponseWriter, r *http.Request, url string) {
+func responseOk(w http.ResponseWriter, r *http.Request, link storage.Link, shortURL string) {
+	var schedule []Entry
+	for _, e := range link.Schedule {
+		schedule = append(schedule, Entry{EffectiveFrom: e.EffectiveFrom, URL: e.URL})
+	}
+
+	var variants []Variant
+	for _, v := range link.Variants {
+		variants = append(variants, Variant{Name: v.Name, URL: v.URL, Weight: v.Weight, Clicks: v.Clicks})
+	}
+
+	var app *App
+	if link.App.URL != "" {
+		app = &App{URL: link.App.URL, IOSFallback: link.App.IOSFallback, AndroidFallback: link.App.AndroidFallback}
+	}
+
+	var clicksLeft *int
+	if link.MaxClicks > 0 {
+		clicksLeft = &link.ClicksLeft
+	}
+
+	var interstitial *Interstitial
+	if link.Interstitial.Enabled {
+		interstitial = &Interstitial{Message: link.Interstitial.Message}
+	}
+
 	render.JSON(w, r, Response{
 		Response: resp.Ok(),
-		Url:      url,
+		Url:      link.URL,
+		Alias:    link.Alias,
+		ShortURL: shortURL,
+		State:    link.State,
+
+		ActiveFrom:  link.ActiveFrom,
+		ActiveUntil: link.ActiveUntil,
+		Schedule:    schedule,
+		Variants:    variants,
+		Targets:     targets.FromStorage(link.Targets),
+		App:         app,
+		Protected:   link.PasswordHash != "",
+		MaxClicks:   link.MaxClicks,
+		ClicksLeft:  clicksLeft,
+
+		Interstitial: interstitial,
+		CreatedAt:    link.CreatedAt,
 	})
 }
@@ -0,0 +1,118 @@
+package interstitial
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+)
+
+type InterstitialSetter interface {
+	GetWorkspace(slug string) (storage.Workspace, error)
+	SetInterstitial(scope storage.Scope, alias string, interstitial storage.Interstitial) error
+}
+
+// Request forces a warning page in front of a link, or removes it.
+type Request struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty" validate:"max=1024"`
+}
+
+type Response struct {
+	resp.Response
+	Alias   string `json:"alias,omitempty"`
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// New sets the interstitial of a link in the workspace named by the slug
+// parameter. The link's domain travels in the domain query parameter,
+// empty for the default domain.
+func New(log *slog.Logger, setter InterstitialSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.interstitial.New"
+		log = log.With(slog.String("operation", op))
+
+		slug := chi.URLParam(r, "slug")
+
+		alias, err := alias.FromRequest(r)
+		if err != nil || alias == "" {
+			log.Info("missing alias")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("missing alias"))
+			return
+		}
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			errors.As(err, &validationErrors)
+
+			log.Error("failed to validate request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validationErrors))
+			return
+		}
+
+		ws, err := setter.GetWorkspace(slug)
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceNotFound) {
+				log.Info("workspace not found", slog.String("slug", slug))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("workspace not found"))
+				return
+			}
+			log.Error("failed to get workspace", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to get workspace"))
+			return
+		}
+
+		scope := storage.Scope{
+			WorkspaceID:     ws.ID,
+			Domain:          workspace.NormalizeHost(r.URL.Query().Get("domain")),
+			CaseInsensitive: ws.CaseInsensitive,
+		}
+		interstitial := storage.Interstitial{Enabled: req.Enabled, Message: req.Message}
+		if !interstitial.Enabled {
+			interstitial.Message = ""
+		}
+
+		if err := setter.SetInterstitial(scope, alias, interstitial); err != nil {
+			if errors.Is(err, storage.ErrUrlNotFound) {
+				log.Info("url not found for interstitial", slog.String("alias", alias))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("url not found"))
+				return
+			}
+			log.Error("failed to set interstitial", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to set interstitial"))
+			return
+		}
+
+		log.Info("interstitial updated", slog.String("alias", alias), slog.Bool("enabled", interstitial.Enabled))
+
+		render.JSON(w, r, Response{
+			Response: resp.Ok(),
+			Alias:    scope.Key(alias),
+			Enabled:  interstitial.Enabled,
+			Message:  interstitial.Message,
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package list
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/shorturl"
+	"URL-Shortener/internal/storage"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+type URLLister interface {
+	ListURLs(scope storage.Scope, prefix string, limit int, offset int) ([]storage.Link, error)
+}
+
+type Link struct {
+	Alias    string   `json:"alias"`
+	ShortURL string   `json:"short_url"`
+	Url      string   `json:"url"`
+	Title    string   `json:"title,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Links []Link `json:"links"`
+}
+
+func New(log *slog.Logger, lister URLLister, shortURL *shorturl.Builder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.list.New"
+		log = log.With(slog.String("operation", op))
+
+		limit, ok := intParam(r, "limit", defaultLimit)
+		if !ok || limit <= 0 {
+			log.Info("invalid limit", slog.String("limit", r.URL.Query().Get("limit")))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid limit"))
+			return
+		}
+		limit = min(limit, maxLimit)
+
+		offset, ok := intParam(r, "offset", 0)
+		if !ok || offset < 0 {
+			log.Info("invalid offset", slog.String("offset", r.URL.Query().Get("offset")))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid offset"))
+			return
+		}
+
+		// prefix lists a branch of hierarchical aliases, e.g. "eng/".
+		prefix := strings.TrimLeft(r.URL.Query().Get("prefix"), "/")
+
+		links, err := lister.ListURLs(workspace.Scope(r), prefix, limit, offset)
+		if err != nil {
+			log.Error("failed to list urls", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to list urls"))
+			return
+		}
+
+		domain := workspace.DomainFromContext(r.Context())
+
+		out := make([]Link, 0, len(links))
+		for _, link := range links {
+			out = append(out, Link{
+				Alias:    link.Alias,
+				ShortURL: shortURL.Build(r, domain, link.Alias),
+				Url:      link.URL,
+				Title:    link.Title,
+				Tags:     link.Tags,
+			})
+		}
+
+		render.JSON(w, r, Response{
+			Response: resp.Ok(),
+			Links:    out,
+		})
+	}
+}
+
+func intParam(r *http.Request, name string, def int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(raw)
+	return n, err == nil
+}
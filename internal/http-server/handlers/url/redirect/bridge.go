@@ -0,0 +1,66 @@
+package redirect
+
+import (
+	"URL-Shortener/internal/lib/logger/sl"
+	"html/template"
+	"log/slog"
+	"net/http"
+)
+
+// bridgeTimeout is how long the bridge page waits for the app to open
+// before it sends the visitor to the fallback, in milliseconds.
+const bridgeTimeout = 1500
+
+type bridgePage struct {
+	// App is trusted: applink.Normalize rejects schemes that run code.
+	App      template.URL
+	Fallback string
+	Timeout  int
+}
+
+var bridgeTmpl = template.Must(template.New("bridge").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Opening the app…</title>
+<style>
+body { font-family: sans-serif; max-width: 30em; margin: 3em auto; padding: 0 1em; text-align: center; }
+a.button { display: inline-block; padding: .6em 1.2em; margin: .5em; border: 1px solid #888; border-radius: .3em; text-decoration: none; }
+</style>
+</head>
+<body>
+<p>Opening the app…</p>
+<p><a class="button" href="{{.App}}">Open in app</a> <a class="button" href="{{.Fallback}}">Continue without it</a></p>
+<script>
+(function () {
+	var timer = setTimeout(function () { location.replace({{.Fallback}}); }, {{.Timeout}});
+	function cancel() { clearTimeout(timer); }
+	document.addEventListener("visibilitychange", function () { if (document.hidden) cancel(); });
+	window.addEventListener("pagehide", cancel);
+	location.href = {{.App}};
+})();
+</script>
+</body>
+</html>
+`))
+
+// serveBridge answers a mobile visitor with a page that tries to open
+// app and goes on to fallback if it does not open. The page is hidden
+// once the app takes over; the fallback is then cancelled so that coming
+// back to the browser does not land on it.
+func serveBridge(w http.ResponseWriter, log *slog.Logger, app string, fallback string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	err := bridgeTmpl.Execute(w, bridgePage{
+		App:      template.URL(app),
+		Fallback: fallback,
+		Timeout:  bridgeTimeout,
+	})
+	if err != nil {
+		log.Error("failed to render app bridge", sl.Err(err))
+	}
+}
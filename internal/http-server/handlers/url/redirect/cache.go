@@ -0,0 +1,38 @@
+package redirect
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// expireAt lets caches keep a redirect only until at, when the link's
+// destination or availability changes. Permanent redirects would be
+// cached for good, so they are downgraded to their temporary
+// counterparts; the returned code is the one to use.
+func expireAt(w http.ResponseWriter, code int, at time.Time) int {
+	// Rounded down so that no cache outlives the switch.
+	maxAge := int64(time.Until(at) / time.Second)
+	w.Header().Set("Cache-Control", "max-age="+strconv.FormatInt(max(maxAge, 0), 10))
+	w.Header().Set("Expires", at.UTC().Format(http.TimeFormat))
+
+	return temporary(code)
+}
+
+// noStore keeps caches from storing a redirect at all and returns the
+// code to use, see expireAt.
+func noStore(w http.ResponseWriter, code int) int {
+	w.Header().Set("Cache-Control", "no-store")
+	return temporary(code)
+}
+
+func temporary(code int) int {
+	switch code {
+	case http.StatusMovedPermanently:
+		return http.StatusFound
+	case http.StatusPermanentRedirect:
+		return http.StatusTemporaryRedirect
+	default:
+		return code
+	}
+}
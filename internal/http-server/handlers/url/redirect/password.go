@@ -0,0 +1,134 @@
+package redirect
+
+import (
+	"URL-Shortener/internal/http-server/middleware/forwarded"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/linkpass"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/ratelimit"
+	"URL-Shortener/internal/storage"
+	"fmt"
+	"github.com/go-chi/render"
+	"html/template"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPasswordForm bounds the body of a password form submission.
+const maxPasswordForm = 4 << 10
+
+// PasswordGate guards password-protected links. Visitors who gave the
+// password are remembered with a signed cookie; wrong attempts are
+// limited per link and client.
+type PasswordGate struct {
+	Signer  *linkpass.Signer
+	Limiter *ratelimit.Limiter
+}
+
+type passwordPage struct {
+	Alias string
+	Error string
+}
+
+var passwordTmpl = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body { font-family: sans-serif; max-width: 30em; margin: 3em auto; padding: 0 1em; }
+input[type=password] { width: 100%; box-sizing: border-box; padding: .4em; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Password required</h1>
+<p>The link <strong>{{.Alias}}</strong> is protected by a password.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post">
+<input type="password" name="password" aria-label="Password" required autofocus autocomplete="current-password">
+<p><button type="submit">Continue</button></p>
+</form>
+</body>
+</html>
+`))
+
+// unlock reports whether the visitor may follow link. Otherwise it has
+// answered with the password form, or, after a correct password, with a
+// redirect back to the link that now carries the cookie.
+func (g PasswordGate) unlock(w http.ResponseWriter, r *http.Request, log *slog.Logger, scope storage.Scope, link storage.Link) bool {
+	now := time.Now()
+	key := fmt.Sprintf("%d\x00%s\x00%s", scope.WorkspaceID, scope.Domain, link.Alias)
+	cookie := linkpass.CookieName(key)
+
+	if c, err := r.Cookie(cookie); err == nil && g.Signer.Verify(c.Value, key, link.PasswordHash, now) {
+		return true
+	}
+
+	if r.Method != http.MethodPost {
+		servePasswordForm(w, r, log, http.StatusUnauthorized, link.Alias, "")
+		return false
+	}
+
+	client := key + "\x00" + forwarded.ClientIP(r).String()
+	if ok, retryAfter := g.Limiter.Take(client, now); !ok {
+		log.Warn("too many wrong passwords", "alias", link.Alias, slog.String("client", forwarded.ClientIP(r).String()))
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		servePasswordForm(w, r, log, http.StatusTooManyRequests, link.Alias, "Too many wrong passwords, please try again later.")
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordForm)
+	if err := r.ParseForm(); err != nil {
+		servePasswordForm(w, r, log, http.StatusBadRequest, link.Alias, "Invalid form.")
+		return false
+	}
+
+	if !linkpass.Check(link.PasswordHash, r.PostForm.Get("password")) {
+		log.Info("wrong link password", "alias", link.Alias)
+		servePasswordForm(w, r, log, http.StatusUnauthorized, link.Alias, "Wrong password.")
+		return false
+	}
+	g.Limiter.Reset(client)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookie,
+		Value:    g.Signer.Sign(key, link.PasswordHash, now),
+		Path:     "/",
+		MaxAge:   int(g.Signer.TTL() / time.Second),
+		HttpOnly: true,
+		Secure:   forwarded.Scheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	// Reloads then are plain GETs that the cookie lets through.
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+	return false
+}
+
+// servePasswordForm asks for the password: with a form for browsers and
+// a JSON error for API clients.
+func servePasswordForm(w http.ResponseWriter, r *http.Request, log *slog.Logger, status int, alias string, msg string) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		if msg == "" {
+			msg = "this link is protected by a password"
+		}
+		render.Status(r, status)
+		render.JSON(w, r, resp.Error(msg))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := passwordTmpl.Execute(w, passwordPage{Alias: alias, Error: msg}); err != nil {
+		log.Error("failed to render password form", sl.Err(err))
+	}
+}
@@ -0,0 +1,23 @@
+package redirect
+
+import (
+	"net/http"
+	"strings"
+)
+
+// trailingSegments returns the escaped path segments of r that follow
+// the matched link alias within the requested alias. They are taken from
+// the raw request path because the router's URL format middleware strips
+// file extensions from the last segment.
+func trailingSegments(r *http.Request, requested string, matched string) []string {
+	n := strings.Count(requested, "/") - strings.Count(matched, "/")
+	if n <= 0 {
+		return nil
+	}
+
+	raw := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	if n > len(raw) {
+		n = len(raw)
+	}
+	return raw[len(raw)-n:]
+}
@@ -0,0 +1,31 @@
+package redirect
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"github.com/go-chi/render"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Placeholders are the pages links that cannot be followed redirect to,
+// with "{alias}" replaced. Empty ones answer with a JSON error.
+type Placeholders struct {
+	Reserved     string
+	NotYetActive string
+	Ended        string
+	UsedUp       string
+}
+
+// servePlaceholder answers for a link that cannot be followed right now.
+// The redirect must not be cached since the link may come live later.
+func servePlaceholder(w http.ResponseWriter, r *http.Request, placeholder string, alias string, status int, msg string) {
+	if placeholder == "" {
+		render.Status(r, status)
+		render.JSON(w, r, resp.Error(msg))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, strings.ReplaceAll(placeholder, "{alias}", url.QueryEscape(alias)), http.StatusFound)
+}
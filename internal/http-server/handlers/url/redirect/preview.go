@@ -0,0 +1,148 @@
+package redirect
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"github.com/go-chi/render"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultWarning is shown on interstitials without a message of their own.
+const defaultWarning = "This link leads to a site outside of our control. Make sure you trust it before you continue."
+
+// PreviewResponse describes a link to API clients asking for a preview.
+type PreviewResponse struct {
+	resp.Response
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// Varies reports that visitors may be sent elsewhere depending on
+	// their device, language, location or variant.
+	Varies bool `json:"varies,omitempty"`
+	// Hidden reports that URL is left out because the link has a click
+	// limit.
+	Hidden bool `json:"hidden,omitempty"`
+}
+
+type previewPage struct {
+	Alias       string
+	Destination string
+	Title       string
+	CreatedAt   string
+	Varies      bool
+	Hidden      bool
+	// Warning is set for an interstitial, which also offers to continue.
+	Warning string
+}
+
+var previewTmpl = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Warning}}Before you continue{{else}}Preview of {{.Alias}}{{end}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 3em auto; padding: 0 1em; }
+.destination { word-break: break-all; font-family: monospace; }
+.warning { border-left: 4px solid #c80; padding: .5em 1em; background: #fff8e6; }
+dt { font-weight: bold; margin-top: .5em; }
+a.button { display: inline-block; padding: .6em 1.2em; border: 1px solid #888; border-radius: .3em; text-decoration: none; }
+</style>
+</head>
+<body>
+{{if .Warning}}
+<h1>Before you continue</h1>
+<p class="warning">{{.Warning}}</p>
+{{else}}
+<h1>Preview of {{.Alias}}</h1>
+{{end}}
+<dl>
+<dt>Destination</dt>
+{{if .Hidden}}<dd>Hidden, this link can only be opened a limited number of times.</dd>
+{{else}}<dd><a class="destination" href="{{.Destination}}" rel="noreferrer">{{.Destination}}</a></dd>{{end}}
+{{if .Title}}<dt>Title</dt>
+<dd>{{.Title}}</dd>{{end}}
+{{if .CreatedAt}}<dt>Created</dt>
+<dd>{{.CreatedAt}}</dd>{{end}}
+</dl>
+{{if .Varies}}<p>Visitors may be sent elsewhere depending on their device, language or location.</p>{{end}}
+{{if .Warning}}<p><a class="button" href="{{.Destination}}" rel="noreferrer">Continue</a></p>{{end}}
+</body>
+</html>
+`))
+
+// servePreview describes link instead of following it: as an HTML page
+// for browsers and as JSON for API clients. The destination of a link
+// with a click limit is left out, since a preview does not use a click.
+func servePreview(w http.ResponseWriter, r *http.Request, log *slog.Logger, link storage.Link) {
+	dest, _, rotate := link.Destination(time.Now())
+	varies := rotate || len(link.Targets) > 0 || link.App.URL != ""
+	hidden := link.MaxClicks > 0
+	if hidden {
+		dest, varies = "", false
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		render.JSON(w, r, PreviewResponse{
+			Response:  resp.Ok(),
+			Alias:     link.Alias,
+			URL:       dest,
+			Title:     link.Title,
+			CreatedAt: link.CreatedAt,
+			Varies:    varies,
+			Hidden:    hidden,
+		})
+		return
+	}
+
+	renderPreview(w, log, previewPage{
+		Alias:       link.Alias,
+		Destination: dest,
+		Title:       link.Title,
+		CreatedAt:   createdAt(link),
+		Varies:      varies,
+		Hidden:      hidden,
+	})
+}
+
+// serveInterstitial shows the warning an admin put in front of link;
+// the visitor continues to dest by hand.
+func serveInterstitial(w http.ResponseWriter, log *slog.Logger, link storage.Link, dest string) {
+	warning := link.Interstitial.Message
+	if warning == "" {
+		warning = defaultWarning
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	renderPreview(w, log, previewPage{
+		Alias:       link.Alias,
+		Destination: dest,
+		Title:       link.Title,
+		CreatedAt:   createdAt(link),
+		Warning:     warning,
+	})
+}
+
+func renderPreview(w http.ResponseWriter, log *slog.Logger, page previewPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := previewTmpl.Execute(w, page); err != nil {
+		log.Error("failed to render preview", sl.Err(err))
+	}
+}
+
+func createdAt(link storage.Link) string {
+	if link.CreatedAt == nil {
+		return ""
+	}
+	return link.CreatedAt.Format("2 January 2006, 15:04 MST")
+}
@@ -1,25 +1,54 @@
 package redirect
 
 import (
+	"URL-Shortener/internal/http-server/middleware/forwarded"
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/applink"
+	"URL-Shortener/internal/lib/geoip"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/passthrough"
+	"URL-Shortener/internal/lib/random"
+	"URL-Shortener/internal/lib/rotation"
+	"URL-Shortener/internal/lib/targeting"
+	"URL-Shortener/internal/lib/useragent"
 	"URL-Shortener/internal/storage"
 	"errors"
-	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 	"log/slog"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 )
 
-type URLGet interface {
-	GetUrl(alias string) (string, error)
+type LinkMatcher interface {
+	MatchLink(scope storage.Scope, alias string) (storage.Link, error)
+	CountVariantClick(id int64) error
+	TakeClick(scope storage.Scope, alias string) (bool, error)
 }
 
-func New(log *slog.Logger, get URLGet) http.HandlerFunc {
+// Locator resolves client addresses for location targeting rules.
+type Locator interface {
+	Lookup(ip net.IP) (geoip.Location, error)
+}
+
+// New returns the redirect handler. notFound, if not nil, answers for
+// aliases that do not exist instead of a JSON error. Links that cannot be
+// followed yet or anymore are sent to their placeholder page, and
+// password-protected ones ask for their password first; the form posts
+// back to the link. A trailing '+' on the alias, or ?preview=1, shows
+// where the link goes instead of following it.
+func New(log *slog.Logger, matcher LinkMatcher, gen *random.Generator, aliasLength int, notFound http.Handler, placeholders Placeholders, locator Locator, gate PasswordGate) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.redirect.New"
 		log = log.With(slog.String("operation", op))
 
-		alias := chi.URLParam(r, "alias")
-		if alias == "" {
+		alias, err := alias.FromRequest(r)
+		alias, preview := strings.CutSuffix(alias, "+")
+		preview = preview || r.URL.Query().Get("preview") == "1"
+		if err != nil || alias == "" {
 			log.Info("alias is empty")
 			render.Status(r, http.StatusBadRequest)
 			render.JSON(w, r, "Empty Alias")
@@ -27,20 +56,190 @@ func New(log *slog.Logger, get URLGet) http.HandlerFunc {
 			return
 		}
 
-		resUrl, err := get.GetUrl(alias)
+		length := aliasLength
+		if ws := workspace.FromContext(r.Context()); ws.AliasLength > 0 {
+			length = ws.AliasLength
+		}
+
+		scope := workspace.Scope(r)
+
+		canonical, ok := gen.Admit(alias, length, scope.CaseInsensitive)
+		if !ok {
+			log.Info("alias failed check character validation", "alias", alias)
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("this link looks mistyped, please check it and try again"))
+			return
+		}
+		alias = canonical
+
+		link, err := matcher.MatchLink(scope, alias)
 		if err != nil {
 			if errors.Is(err, storage.ErrUrlNotFound) {
+				if fallback := workspace.DomainFromContext(r.Context()).FallbackURL; fallback != "" {
+					log.Info("url not found, using domain fallback", "alias", alias)
+					http.Redirect(w, r, fallback, http.StatusFound)
+					return
+				}
 				log.Info("url not found", "alias", alias)
+				if notFound != nil {
+					notFound.ServeHTTP(w, r)
+					return
+				}
 				render.Status(r, http.StatusNotFound)
 				render.JSON(w, r, "Url not found")
 				return
 			}
-			log.Error(err.Error(), "url", resUrl)
+			log.Error(err.Error(), "alias", alias)
 			render.Status(r, http.StatusInternalServerError)
 			render.JSON(w, r, "Internal server error")
 			return
 		}
 
-		http.Redirect(w, r, resUrl, http.StatusFound)
+		if link.State == storage.StateReserved {
+			servePlaceholder(w, r, placeholders.Reserved, link.Alias, http.StatusNotFound, "this link is not live yet")
+			return
+		}
+
+		switch link.Window(time.Now()) {
+		case -1:
+			log.Info("link is not active yet", "alias", link.Alias)
+			servePlaceholder(w, r, placeholders.NotYetActive, link.Alias, http.StatusNotFound, "this link is not active yet")
+			return
+		case 1:
+			log.Info("link has ended", "alias", link.Alias)
+			servePlaceholder(w, r, placeholders.Ended, link.Alias, http.StatusGone, "this link has ended")
+			return
+		}
+
+		protected := link.PasswordHash != ""
+		switch {
+		case protected:
+			if !gate.unlock(w, r, log, scope, link) {
+				return
+			}
+		case r.Method == http.MethodPost:
+			render.Status(r, http.StatusMethodNotAllowed)
+			render.JSON(w, r, resp.Error("method not allowed"))
+			return
+		}
+
+		limited := link.MaxClicks > 0
+		if limited && link.ClicksLeft <= 0 {
+			log.Info("link has no clicks left", "alias", link.Alias)
+			servePlaceholder(w, r, placeholders.UsedUp, link.Alias, http.StatusGone, "this link has been used up")
+			return
+		}
+
+		if preview {
+			// Previews do not count as clicks, so they hide where
+			// click-limited links go.
+			log.Info("serving preview", "alias", link.Alias)
+			servePreview(w, r, log, link)
+			return
+		}
+
+		if limited && useragent.Parse(r.UserAgent()).Device == useragent.DeviceBot {
+			// Bots, e.g. link unfurlers, must not use up clicks, so they
+			// do not get to follow the link at all.
+			log.Info("refused bot on click-limited link", "alias", link.Alias)
+			render.Status(r, http.StatusForbidden)
+			render.JSON(w, r, resp.Error("this link can only be opened by people"))
+			return
+		}
+
+		dest, next, rotate := link.Destination(time.Now())
+		client := targeting.Client{
+			UserAgent:      r.UserAgent(),
+			AcceptLanguage: r.Header.Get("Accept-Language"),
+			Locate: func() geoip.Location {
+				loc, err := locator.Lookup(forwarded.ClientIP(r))
+				if err != nil {
+					// Location rules then simply do not match.
+					log.Error("failed to locate client", sl.Err(err))
+				}
+				return loc
+			},
+		}
+		if i := targeting.Match(link.Targets, client); i >= 0 {
+			dest, rotate = link.Targets[i].URL, false
+		}
+		var variant *storage.Variant
+		if rotate {
+			weights := make([]int, len(link.Variants))
+			for i, v := range link.Variants {
+				weights[i] = v.Weight
+			}
+			// Keyed by alias too so that links split independently.
+			if i := rotation.Pick(weights, visitorID(w, r)+"\x00"+link.Alias); i >= 0 {
+				variant = &link.Variants[i]
+				dest = variant.URL
+			}
+		}
+
+		resUrl, err := passthrough.Apply(dest, link.Passthrough, trailingSegments(r, alias, link.Alias), r.URL.Query())
+		if err != nil {
+			log.Info("rejected passthrough path", "alias", alias, sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid path"))
+			return
+		}
+
+		// Taken only once the destination is known, so that rejected
+		// requests do not use up clicks.
+		if limited {
+			ok, err := matcher.TakeClick(scope, link.Alias)
+			if err != nil {
+				log.Error("failed to take click", "alias", link.Alias, sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, "Internal server error")
+				return
+			}
+			if !ok {
+				log.Info("link has no clicks left", "alias", link.Alias)
+				servePlaceholder(w, r, placeholders.UsedUp, link.Alias, http.StatusGone, "this link has been used up")
+				return
+			}
+		}
+
+		// Counted only now that the visitor is sent on.
+		if variant != nil {
+			if err := matcher.CountVariantClick(variant.ID); err != nil {
+				log.Error("failed to count variant click", "variant", variant.Name, sl.Err(err))
+			}
+		}
+
+		code := http.StatusFound
+		if ws := workspace.FromContext(r.Context()); ws.RedirectCode != 0 {
+			code = ws.RedirectCode
+		}
+
+		if link.Interstitial.Enabled {
+			serveInterstitial(w, log, link, resUrl)
+			return
+		}
+
+		if link.App.URL != "" {
+			// Mobile visitors get the bridge page instead.
+			w.Header().Add("Vary", "User-Agent")
+			if fallback, ok := applink.Fallback(link.App, useragent.Parse(r.UserAgent()).OS, resUrl); ok {
+				serveBridge(w, log, link.App.URL, fallback)
+				return
+			}
+		}
+
+		targeted := len(link.Targets) > 0
+		if targeted {
+			w.Header().Add("Vary", "User-Agent, Accept-Language")
+		}
+
+		switch {
+		case rotate, targeted, protected, limited:
+			// The destination depends on the visitor.
+			code = noStore(w, code)
+		case next != nil:
+			code = expireAt(w, code, *next)
+		}
+
+		http.Redirect(w, r, resUrl, code)
 	}
 }
@@ -0,0 +1,37 @@
+package redirect
+
+import (
+	"URL-Shortener/internal/http-server/middleware/forwarded"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// visitorCookie keeps a visitor on the same variant of every link.
+const visitorCookie = "us_vid"
+
+const visitorCookieAge = 365 * 24 * time.Hour
+
+// visitorID returns the visitor's id from its cookie, setting one if it
+// is missing. A new id is derived from the client address and user agent
+// so that visitors who drop cookies still mostly see the same variant.
+func visitorID(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(visitorCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+
+	sum := sha256.Sum256([]byte(forwarded.ClientIP(r).String() + "\x00" + r.UserAgent()))
+	id := hex.EncodeToString(sum[:16])
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     visitorCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(visitorCookieAge / time.Second),
+		HttpOnly: true,
+		Secure:   forwarded.Scheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
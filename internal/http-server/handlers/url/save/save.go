@@ -1,74 +1,97 @@
 package save
 
 import (
+	"URL-Shortener/internal/http-server/handlers/url/targets"
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
 	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/applink"
+	"URL-Shortener/internal/lib/destination"
+	"URL-Shortener/internal/lib/linkpass"
 	"URL-Shortener/internal/lib/logger/sl"
 	"URL-Shortener/internal/lib/random"
+	"URL-Shortener/internal/lib/shorturl"
 	"URL-Shortener/internal/storage"
 	"errors"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator/v10"
 	"log/slog"
 	"net/http"
-	"net/url"
-	"regexp"
 	"strconv"
-	"strings"
-	"sync"
+	"time"
 )
 
-var (
-	validate   *validator.Validate
-	initOnce   sync.Once
-	aliasRegex = regexp.MustCompile("^[a-zA-Z0-9_-]+$")
-)
-
-func initValidator() {
-	validate = validator.New()
-	validate.RegisterValidation("alphanum", func(fl validator.FieldLevel) bool {
-		return aliasRegex.MatchString(fl.Field().String())
-	})
+type URLSaver interface {
+	SaveURL(scope storage.Scope, link storage.Link) (int64, error)
+	AliasExists(scope storage.Scope, alias string) (bool, error)
 }
+type Request struct {
+	Alias string   `json:"alias,omitempty"`
+	URL   string   `json:"url" validate:"required_without_all=Reserve Variants"`
+	Title string   `json:"title,omitempty" validate:"max=256"`
+	Notes string   `json:"notes,omitempty" validate:"max=4096"`
+	Tags  []string `json:"tags,omitempty" validate:"max=32,dive,required,max=64"`
 
-func getValidator() *validator.Validate {
-	initOnce.Do(initValidator)
-	return validate
-}
+	Passthrough Passthrough `json:"passthrough,omitempty"`
 
-func isValidURL(u string) bool {
-	parsed, err := url.Parse(u)
-	if err != nil {
-		return false
-	}
+	// Reserve claims the alias without a destination; it is set later
+	// through an update. URL must then be empty.
+	Reserve bool `json:"reserve,omitempty" validate:"excluded_with=URL"`
 
-	if parsed.Host == "" {
-		return false
-	}
-	return true
+	// ActiveFrom and ActiveUntil limit when the link redirects. They are
+	// RFC 3339 timestamps in any time zone and are stored in UTC.
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+
+	// Variants split traffic between destinations by weight, e.g. for
+	// A/B tests. URL may then be omitted.
+	Variants []Variant `json:"variants,omitempty" validate:"omitempty,excluded_with=Reserve,min=1,max=10,unique=Name,dive"`
+
+	// Targets send clients matching a rule elsewhere, e.g. iOS devices to
+	// the App Store.
+	Targets []targets.Rule `json:"targets,omitempty" validate:"max=20,dive"`
+
+	// App opens the link in a mobile app, falling back to the store or
+	// the web when it is not installed.
+	App App `json:"app,omitempty"`
+
+	// Password must be given by visitors before they are redirected.
+	Password string `json:"password,omitempty" validate:"max=72"`
+
+	// MaxClicks makes the link answer 410 Gone after this many
+	// redirects, e.g. 1 for a single-use link. It is stored as an INT.
+	MaxClicks int `json:"max_clicks,omitempty" validate:"min=0,max=2147483647"`
 }
 
-func normalizeUrl(url string) string {
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		return "https://" + url
-	}
-	return url
+// App mirrors storage.AppLink.
+type App struct {
+	URL             string `json:"url,omitempty" validate:"max=2048"`
+	IOSFallback     string `json:"ios_fallback,omitempty" validate:"max=2048"`
+	AndroidFallback string `json:"android_fallback,omitempty" validate:"max=2048"`
 }
 
-type URLSaver interface {
-	SaveURL(alias string, urlToSave string) (int64, error)
-	AliasExists(alias string) (bool, error)
+type Variant struct {
+	Name   string `json:"name" validate:"required,max=64"`
+	URL    string `json:"url" validate:"required"`
+	Weight int    `json:"weight" validate:"min=1,max=10000"`
 }
-type Request struct {
-	Alias string `json:"alias,omitempty"`
-	URL   string `json:"url" validate:"required"`
+
+// Passthrough mirrors storage.Passthrough; see package passthrough for
+// the query and fragment modes.
+type Passthrough struct {
+	Path     bool   `json:"path,omitempty"`
+	Query    string `json:"query,omitempty" validate:"omitempty,oneof=off link request append"`
+	Fragment string `json:"fragment,omitempty" validate:"omitempty,oneof=link request"`
 }
 
 type Response struct {
 	resp.Response
-	Alias string `json:"alias,omitempty"`
+	Alias    string `json:"alias,omitempty"`
+	ShortURL string `json:"short_url,omitempty"`
 }
 
-func New(log *slog.Logger, urlSaver URLSaver, aliasLength int, maxAttempts int) http.HandlerFunc {
+func New(log *slog.Logger, urlSaver URLSaver, policy *alias.Policy, gen *random.Generator, shortURL *shorturl.Builder, aliasLength int, maxAttempts int) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.save.New"
 		log = log.With(
@@ -83,7 +106,7 @@ func New(log *slog.Logger, urlSaver URLSaver, aliasLength int, maxAttempts int)
 			return
 		}
 
-		if err := getValidator().Struct(req); err != nil {
+		if err := validate.Struct(req); err != nil {
 			var validationErrors validator.ValidationErrors
 			errors.As(err, &validationErrors)
 
@@ -93,30 +116,144 @@ func New(log *slog.Logger, urlSaver URLSaver, aliasLength int, maxAttempts int)
 			return
 		}
 
-		alias := req.Alias
+		scope := workspace.Scope(r)
+		length := aliasLength
+		if ws := workspace.FromContext(r.Context()); ws.AliasLength > 0 {
+			length = ws.AliasLength
+		}
+
+		customAlias := policy.Normalize(req.Alias)
+		if customAlias != "" {
+			if violations := policy.Check(customAlias); len(violations) > 0 {
+				log.Info("alias violates policy", slog.String("alias", customAlias))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.AliasPolicyError("Alias", violations))
+				return
+			}
 
-		if alias == "" {
-			generatedAlias, err := generateUniqueAlias(urlSaver, log, aliasLength, maxAttempts)
+			admitted, ok := gen.Admit(customAlias, length, scope.CaseInsensitive)
+			if !ok {
+				log.Info("alias has an invalid check character", slog.String("alias", customAlias))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.AliasPolicyError("Alias", []alias.Violation{alias.CheckCharViolation}))
+				return
+			}
+			customAlias = admitted
+		}
+
+		if customAlias == "" {
+			generatedAlias, err := generateUniqueAlias(urlSaver, scope, policy, gen, log, length, maxAttempts)
 			if err != nil {
 				log.Error("failed to generate unique alias", sl.Err(err))
 				render.Status(r, http.StatusInternalServerError)
 				render.JSON(w, r, resp.Error("failed to generate unique alias"))
 				return
 			}
-			alias = generatedAlias
-			log.Info("generated unique alias", slog.String("alias", alias))
+			customAlias = generatedAlias
+			log.Info("generated unique alias", slog.String("alias", customAlias))
+		}
+
+		customAlias = scope.Key(customAlias)
+
+		activeFrom, activeUntil := utc(req.ActiveFrom), utc(req.ActiveUntil)
+		if activeFrom != nil && activeUntil != nil && !activeFrom.Before(*activeUntil) {
+			log.Info("invalid activation window")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("active_until must be after active_from"))
+			return
+		}
+
+		variants := make([]storage.Variant, 0, len(req.Variants))
+		for _, v := range req.Variants {
+			dest, ok := destination.Normalize(v.URL)
+			if !ok {
+				log.Error("invalid variant URL format", slog.String("url", dest))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid URL format for variant "+v.Name))
+				return
+			}
+			variants = append(variants, storage.Variant{Name: v.Name, URL: dest, Weight: v.Weight})
 		}
 
-		normalizedUrl := normalizeUrl(req.URL)
-		if !isValidURL(normalizedUrl) {
-			log.Error("invalid URL format", slog.String("url", normalizedUrl))
+		rules, err := targets.ToStorage(req.Targets)
+		if err != nil {
+			log.Info("invalid targeting rule", sl.Err(err))
 			render.Status(r, http.StatusBadRequest)
-			render.JSON(w, r, resp.Error("invalid URL format"))
+			render.JSON(w, r, resp.Error(err.Error()))
 			return
 		}
 
-		id, err := urlSaver.SaveURL(alias, normalizedUrl)
+		app, err := applink.Normalize(storage.AppLink(req.App))
+		if err != nil {
+			log.Info("invalid app link", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		var passwordHash string
+		if req.Password != "" {
+			passwordHash, err = linkpass.Hash(req.Password)
+			if errors.Is(err, linkpass.ErrTooLong) {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error(err.Error()))
+				return
+			}
+			if err != nil {
+				log.Error("failed to hash password", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("failed to save url"))
+				return
+			}
+		}
+
+		state := storage.StateActive
+		var normalizedUrl string
+		switch {
+		case req.Reserve:
+			state = storage.StateReserved
+		case req.URL == "":
+			// Shown in listings; redirects use the variants.
+			normalizedUrl = variants[0].URL
+		default:
+			var ok bool
+			normalizedUrl, ok = destination.Normalize(req.URL)
+			if !ok {
+				log.Error("invalid URL format", slog.String("url", normalizedUrl))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid URL format"))
+				return
+			}
+		}
+
+		id, err := urlSaver.SaveURL(scope, storage.Link{
+			Alias: customAlias,
+			URL:   normalizedUrl,
+			Title: req.Title,
+			Notes: req.Notes,
+			Tags:  req.Tags,
+			Passthrough: storage.Passthrough{
+				Path:     req.Passthrough.Path,
+				Query:    req.Passthrough.Query,
+				Fragment: req.Passthrough.Fragment,
+			},
+			State:       state,
+			ActiveFrom:  activeFrom,
+			ActiveUntil: activeUntil,
+			Variants:    variants,
+			Targets:     rules,
+			App:         app,
+
+			PasswordHash: passwordHash,
+			MaxClicks:    req.MaxClicks,
+		})
 		if err != nil {
+			if errors.Is(err, storage.ErrAliasTombstoned) {
+				log.Info("alias was recently deleted", slog.String("alias", customAlias))
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, resp.Error("alias was recently deleted and cannot be reused yet"))
+				return
+			}
 			if errors.Is(err, storage.ErrAliasExists) {
 				log.Error("Alias already exist", slog.String("url", req.URL))
 				render.Status(r, http.StatusConflict)
@@ -131,33 +268,45 @@ func New(log *slog.Logger, urlSaver URLSaver, aliasLength int, maxAttempts int)
 		}
 		log.Info("saved url", slog.String("url", req.URL), slog.String("id", strconv.FormatInt(id, 10)))
 
-		responseOk(w, r, alias)
+		responseOk(w, r, customAlias, shortURL.Build(r, workspace.DomainFromContext(r.Context()), customAlias))
 	}
 
 }
 
-func responseOk(w http.ResponseWriter, r *http.Request, alias string) {
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func responseOk(w http.ResponseWriter, r *http.Request, alias string, shortURL string) {
 	render.JSON(w, r, Response{
 		Response: resp.Ok(),
 		Alias:    alias,
+		ShortURL: shortURL,
 	})
 }
 
-func generateUniqueAlias(urlSaver URLSaver, log *slog.Logger, aliasLength int, maxAttempts int) (string, error) {
+func generateUniqueAlias(urlSaver URLSaver, scope storage.Scope, policy *alias.Policy, gen *random.Generator, log *slog.Logger, aliasLength int, maxAttempts int) (string, error) {
 	for i := 0; i < maxAttempts; i++ {
-		alias := random.NewRandomAlias(aliasLength)
+		candidate := gen.Generate(aliasLength)
+		if len(policy.Check(candidate)) > 0 {
+			continue
+		}
 
-		exists, err := urlSaver.AliasExists(alias)
+		exists, err := urlSaver.AliasExists(scope, candidate)
 		if err != nil {
 			return "", err
 		}
 
 		if !exists {
-			return alias, nil
+			return candidate, nil
 		}
 
 		log.Debug("alias collision, generating new one",
-			slog.String("alias", alias),
+			slog.String("alias", candidate),
 			slog.Int("attempt", i+1),
 		)
 	}
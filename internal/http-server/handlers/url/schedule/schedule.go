@@ -0,0 +1,129 @@
+package schedule
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/destination"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type ScheduleSetter interface {
+	SetSchedule(scope storage.Scope, alias string, entries []storage.ScheduleEntry) error
+}
+
+// Request replaces the whole schedule of a link; an empty list clears it.
+type Request struct {
+	Entries []Entry `json:"entries" validate:"max=32,dive"`
+}
+
+// Entry switches the destination to URL from EffectiveFrom on, an RFC
+// 3339 timestamp in any time zone that is stored in UTC.
+type Entry struct {
+	EffectiveFrom time.Time `json:"effective_from" validate:"required"`
+	URL           string    `json:"url" validate:"required"`
+}
+
+type Response struct {
+	resp.Response
+	Entries []Entry `json:"entries"`
+}
+
+func New(log *slog.Logger, setter ScheduleSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.schedule.New"
+		log = log.With(slog.String("operation", op))
+
+		alias, err := alias.FromRequest(r)
+		if err != nil || alias == "" {
+			log.Info("missing alias")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("missing alias"))
+			return
+		}
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			errors.As(err, &validationErrors)
+
+			log.Error("failed to validate request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validationErrors))
+			return
+		}
+
+		entries := make([]Entry, 0, len(req.Entries))
+		for _, e := range req.Entries {
+			dest, ok := destination.Normalize(e.URL)
+			if !ok {
+				log.Info("invalid URL format", slog.String("url", dest))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid URL format: "+e.URL))
+				return
+			}
+			entries = append(entries, Entry{EffectiveFrom: e.EffectiveFrom.UTC(), URL: dest})
+		}
+
+		// Each entry lasts until the next one, so two entries overlap
+		// exactly when they take effect at the same time.
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].EffectiveFrom.Before(entries[j].EffectiveFrom)
+		})
+		for i := 1; i < len(entries); i++ {
+			if entries[i].EffectiveFrom.Equal(entries[i-1].EffectiveFrom) {
+				log.Info("overlapping schedule entries", slog.Time("effective_from", entries[i].EffectiveFrom))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("schedule entries overlap at "+entries[i].EffectiveFrom.Format(time.RFC3339)))
+				return
+			}
+		}
+
+		schedule := make([]storage.ScheduleEntry, 0, len(entries))
+		for _, e := range entries {
+			schedule = append(schedule, storage.ScheduleEntry{EffectiveFrom: e.EffectiveFrom, URL: e.URL})
+		}
+
+		err = setter.SetSchedule(workspace.Scope(r), alias, schedule)
+		if err != nil {
+			if errors.Is(err, storage.ErrUrlNotFound) {
+				log.Info("url not found for schedule", slog.String("alias", alias))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("url not found"))
+				return
+			}
+			if errors.Is(err, storage.ErrScheduleOverlap) {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("schedule entries overlap"))
+				return
+			}
+			log.Error("failed to set schedule", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to set schedule"))
+			return
+		}
+
+		log.Info("schedule updated", slog.String("alias", alias), slog.Int("entries", len(entries)))
+
+		render.JSON(w, r, Response{
+			Response: resp.Ok(),
+			Entries:  entries,
+		})
+	}
+}
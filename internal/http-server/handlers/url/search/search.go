@@ -0,0 +1,119 @@
+package search
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/shorturl"
+	"URL-Shortener/internal/storage"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type URLSearcher interface {
+	Search(scope storage.Scope, query string, limit int) ([]storage.SearchResult, error)
+	GetDomain(host string) (storage.Domain, error)
+}
+
+type Result struct {
+	Alias    string   `json:"alias"`
+	ShortURL string   `json:"short_url"`
+	Url      string   `json:"url"`
+	Title    string   `json:"title,omitempty"`
+	Notes    string   `json:"notes,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Rank     float32  `json:"rank"`
+	Snippet  string   `json:"snippet,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Results []Result `json:"results"`
+}
+
+func New(log *slog.Logger, searcher URLSearcher, shortURL *shorturl.Builder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.search.New"
+		log = log.With(slog.String("operation", op))
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		if query == "" {
+			log.Info("empty search query")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("query parameter q is required"))
+			return
+		}
+
+		limit := defaultLimit
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n <= 0 {
+				log.Info("invalid search limit", slog.String("limit", raw))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid limit"))
+				return
+			}
+			limit = min(n, maxLimit)
+		}
+
+		results, err := searcher.Search(workspace.Scope(r), query, limit)
+		if err != nil {
+			log.Error("failed to search urls", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to search urls"))
+			return
+		}
+
+		log.Debug("search completed", slog.String("query", query), slog.Int("results", len(results)))
+
+		// Search spans every domain of the workspace, so each distinct
+		// domain is looked up once to build its results' short URLs.
+		domains := map[string]storage.Domain{"": {}}
+		current := workspace.DomainFromContext(r.Context())
+		domains[current.Host] = current
+
+		out := make([]Result, 0, len(results))
+		for _, res := range results {
+			domain, ok := domains[res.Domain]
+			if !ok {
+				domain, err = searcher.GetDomain(res.Domain)
+				if err != nil {
+					log.Warn("failed to get domain of search result", slog.String("domain", res.Domain), sl.Err(err))
+					domain = storage.Domain{Host: res.Domain}
+				}
+				domains[res.Domain] = domain
+			}
+			out = append(out, toResult(res, shortURL.Build(r, domain, res.Alias)))
+		}
+
+		responseOk(w, r, out)
+	}
+}
+
+func toResult(res storage.SearchResult, shortURL string) Result {
+	return Result{
+		Alias:    res.Alias,
+		ShortURL: shortURL,
+		Url:      res.URL,
+		Title:    res.Title,
+		Notes:    res.Notes,
+		Tags:     res.Tags,
+		Rank:     res.Rank,
+		Snippet:  res.Snippet,
+	}
+}
+
+func responseOk(w http.ResponseWriter, r *http.Request, out []Result) {
+	render.JSON(w, r, Response{
+		Response: resp.Ok(),
+		Results:  out,
+	})
+}
@@ -0,0 +1,184 @@
+package targets
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/destination"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/targeting"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type TargetSetter interface {
+	SetTargets(scope storage.Scope, alias string, rules []storage.TargetRule) error
+}
+
+// Request replaces the targeting rules of a link; an empty list removes
+// them. Clients no rule matches get the link's regular destination.
+type Request struct {
+	Rules []Rule `json:"rules" validate:"max=20,dive"`
+}
+
+// Rule matches clients on every non-empty condition.
+type Rule struct {
+	OS        []string `json:"os,omitempty" validate:"dive,oneof=ios android windows macos linux chromeos other"`
+	Devices   []string `json:"devices,omitempty" validate:"dive,oneof=mobile tablet desktop bot"`
+	Browsers  []string `json:"browsers,omitempty" validate:"dive,oneof=chrome safari firefox edge opera samsung other"`
+	Languages []string `json:"languages,omitempty" validate:"dive,required,max=35"`
+	// Countries are ISO 3166-1 alpha-2 codes; "EU" covers the European
+	// Union. Regions are ISO 3166-2 codes such as "US-CA".
+	Countries []string `json:"countries,omitempty" validate:"dive,len=2"`
+	Regions   []string `json:"regions,omitempty" validate:"dive,required,max=6"`
+	URL       string   `json:"url" validate:"required"`
+}
+
+type Response struct {
+	resp.Response
+	Rules []Rule `json:"rules"`
+}
+
+func New(log *slog.Logger, setter TargetSetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.targets.New"
+		log = log.With(slog.String("operation", op))
+
+		alias, err := alias.FromRequest(r)
+		if err != nil || alias == "" {
+			log.Info("missing alias")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("missing alias"))
+			return
+		}
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			errors.As(err, &validationErrors)
+
+			log.Error("failed to validate request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validationErrors))
+			return
+		}
+
+		rules, err := ToStorage(req.Rules)
+		if err != nil {
+			log.Info("invalid targeting rule", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		err = setter.SetTargets(workspace.Scope(r), alias, rules)
+		if err != nil {
+			if errors.Is(err, storage.ErrUrlNotFound) {
+				log.Info("url not found for targeting", slog.String("alias", alias))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("url not found"))
+				return
+			}
+			log.Error("failed to set targeting rules", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to set targeting rules"))
+			return
+		}
+
+		log.Info("targeting rules updated", slog.String("alias", alias), slog.Int("rules", len(rules)))
+
+		render.JSON(w, r, Response{
+			Response: resp.Ok(),
+			Rules:    FromStorage(rules),
+		})
+	}
+}
+
+// ToStorage checks what validation tags cannot, that rules have a
+// condition, a usable URL and well-formed languages and locations, and
+// converts them. Country and region codes are upper-cased.
+func ToStorage(rules []Rule) ([]storage.TargetRule, error) {
+	out := make([]storage.TargetRule, 0, len(rules))
+	for i, rule := range rules {
+		if len(rule.OS)+len(rule.Devices)+len(rule.Browsers)+len(rule.Languages)+len(rule.Countries)+len(rule.Regions) == 0 {
+			return nil, fmt.Errorf("rule %d has no condition, use the link url as the default instead", i+1)
+		}
+
+		for _, l := range rule.Languages {
+			if !targeting.ValidLanguage(l) {
+				return nil, fmt.Errorf("rule %d: invalid language %q", i+1, l)
+			}
+		}
+
+		countries := upper(rule.Countries)
+		for _, c := range countries {
+			if !targeting.ValidCountry(c) {
+				return nil, fmt.Errorf("rule %d: invalid country %q", i+1, c)
+			}
+		}
+
+		regions := upper(rule.Regions)
+		for _, reg := range regions {
+			if !targeting.ValidRegion(reg) {
+				return nil, fmt.Errorf("rule %d: invalid region %q", i+1, reg)
+			}
+		}
+
+		dest, ok := destination.Normalize(rule.URL)
+		if !ok {
+			return nil, fmt.Errorf("rule %d: invalid URL format", i+1)
+		}
+
+		out = append(out, storage.TargetRule{
+			OS:        rule.OS,
+			Devices:   rule.Devices,
+			Browsers:  rule.Browsers,
+			Languages: rule.Languages,
+			Countries: countries,
+			Regions:   regions,
+			URL:       dest,
+		})
+	}
+	return out, nil
+}
+
+func FromStorage(rules []storage.TargetRule) []Rule {
+	out := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, Rule{
+			OS:        rule.OS,
+			Devices:   rule.Devices,
+			Browsers:  rule.Browsers,
+			Languages: rule.Languages,
+			Countries: rule.Countries,
+			Regions:   rule.Regions,
+			URL:       rule.URL,
+		})
+	}
+	return out
+}
+
+func upper(codes []string) []string {
+	if codes == nil {
+		return nil
+	}
+	out := make([]string, len(codes))
+	for i, c := range codes {
+		out[i] = strings.ToUpper(c)
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package update
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/applink"
+	"URL-Shortener/internal/lib/destination"
+	"URL-Shortener/internal/lib/linkpass"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/lib/shorturl"
+	"URL-Shortener/internal/storage"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type URLUpdater interface {
+	UpdateLink(scope storage.Scope, alias string, patch storage.LinkPatch) (storage.Link, error)
+}
+
+// Request holds the attributes to change; omitted ones are kept. Setting
+// url on a reserved link activates it.
+type Request struct {
+	URL         *string      `json:"url,omitempty" validate:"omitempty,min=1"`
+	Title       *string      `json:"title,omitempty" validate:"omitempty,max=256"`
+	Notes       *string      `json:"notes,omitempty" validate:"omitempty,max=4096"`
+	Tags        *[]string    `json:"tags,omitempty" validate:"omitempty,max=32,dive,required,max=64"`
+	Passthrough *Passthrough `json:"passthrough,omitempty"`
+	// ActiveFrom and ActiveUntil change the activation window; null
+	// removes a bound.
+	ActiveFrom  NullTime `json:"active_from"`
+	ActiveUntil NullTime `json:"active_until"`
+	// Variants replaces the variants; an empty list removes them.
+	Variants *[]Variant `json:"variants,omitempty" validate:"omitempty,max=10,unique=Name,dive"`
+	// App replaces the mobile app link; an empty url removes it.
+	App *App `json:"app,omitempty"`
+	// Password replaces the link password; an empty one removes it.
+	Password *string `json:"password,omitempty" validate:"omitempty,max=72"`
+	// MaxClicks sets a new click limit and starts the count over; 0
+	// removes the limit.
+	MaxClicks *int `json:"max_clicks,omitempty" validate:"omitempty,min=0,max=2147483647"`
+}
+
+type App struct {
+	URL             string `json:"url" validate:"max=2048"`
+	IOSFallback     string `json:"ios_fallback" validate:"max=2048"`
+	AndroidFallback string `json:"android_fallback" validate:"max=2048"`
+}
+
+type Variant struct {
+	Name   string `json:"name" validate:"required,max=64"`
+	URL    string `json:"url" validate:"required"`
+	Weight int    `json:"weight" validate:"min=1,max=10000"`
+}
+
+// NullTime is a timestamp that tells an explicit null apart from an
+// omitted field.
+type NullTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+func (t *NullTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	if string(data) == "null" {
+		t.Time = nil
+		return nil
+	}
+	var v time.Time
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	v = v.UTC()
+	t.Time = &v
+	return nil
+}
+
+func (t NullTime) patch() *storage.TimePatch {
+	if !t.Set {
+		return nil
+	}
+	return &storage.TimePatch{Time: t.Time}
+}
+
+type Passthrough struct {
+	Path     bool   `json:"path"`
+	Query    string `json:"query" validate:"omitempty,oneof=off link request append"`
+	Fragment string `json:"fragment" validate:"omitempty,oneof=link request"`
+}
+
+type Response struct {
+	resp.Response
+	Url      string `json:"url,omitempty"`
+	Alias    string `json:"alias,omitempty"`
+	ShortURL string `json:"short_url,omitempty"`
+	State    string `json:"state,omitempty"`
+
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+}
+
+func New(log *slog.Logger, updater URLUpdater, shortURL *shorturl.Builder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.update.New"
+		log = log.With(slog.String("operation", op))
+
+		alias, err := alias.FromRequest(r)
+		if err != nil || alias == "" {
+			log.Info("missing alias")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("missing alias"))
+			return
+		}
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			errors.As(err, &validationErrors)
+
+			log.Error("failed to validate request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validationErrors))
+			return
+		}
+
+		patch := storage.LinkPatch{
+			Title:       req.Title,
+			Notes:       req.Notes,
+			ActiveFrom:  req.ActiveFrom.patch(),
+			ActiveUntil: req.ActiveUntil.patch(),
+			MaxClicks:   req.MaxClicks,
+		}
+		if req.URL != nil {
+			dest, ok := destination.Normalize(*req.URL)
+			if !ok {
+				log.Info("invalid URL format", slog.String("url", dest))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("invalid URL format"))
+				return
+			}
+			patch.URL = &dest
+		}
+		if req.Variants != nil {
+			variants := make([]storage.Variant, 0, len(*req.Variants))
+			for _, v := range *req.Variants {
+				dest, ok := destination.Normalize(v.URL)
+				if !ok {
+					log.Info("invalid variant URL format", slog.String("url", dest))
+					render.Status(r, http.StatusBadRequest)
+					render.JSON(w, r, resp.Error("invalid URL format for variant "+v.Name))
+					return
+				}
+				variants = append(variants, storage.Variant{Name: v.Name, URL: dest, Weight: v.Weight})
+			}
+			patch.Variants = &variants
+		}
+		if req.App != nil {
+			app, err := applink.Normalize(storage.AppLink(*req.App))
+			if err != nil {
+				log.Info("invalid app link", sl.Err(err))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error(err.Error()))
+				return
+			}
+			patch.App = &app
+		}
+		if req.Password != nil {
+			var hash string
+			if *req.Password != "" {
+				hash, err = linkpass.Hash(*req.Password)
+				if errors.Is(err, linkpass.ErrTooLong) {
+					render.Status(r, http.StatusBadRequest)
+					render.JSON(w, r, resp.Error(err.Error()))
+					return
+				}
+				if err != nil {
+					log.Error("failed to hash password", sl.Err(err))
+					render.Status(r, http.StatusInternalServerError)
+					render.JSON(w, r, resp.Error("failed to update url"))
+					return
+				}
+			}
+			patch.PasswordHash = &hash
+		}
+		if req.Tags != nil {
+			patch.Tags = append([]string{}, *req.Tags...)
+		}
+		if req.Passthrough != nil {
+			patch.Passthrough = &storage.Passthrough{
+				Path:     req.Passthrough.Path,
+				Query:    req.Passthrough.Query,
+				Fragment: req.Passthrough.Fragment,
+			}
+		}
+
+		link, err := updater.UpdateLink(workspace.Scope(r), alias, patch)
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidWindow) {
+				log.Info("invalid activation window", slog.String("alias", alias))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("active_until must be after active_from"))
+				return
+			}
+			if errors.Is(err, storage.ErrUrlNotFound) {
+				log.Info("url not found for update", slog.String("alias", alias))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("url not found"))
+				return
+			}
+			log.Error("failed to update url", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to update url"))
+			return
+		}
+
+		log.Info("url updated", slog.String("alias", link.Alias), slog.String("state", link.State))
+
+		render.JSON(w, r, Response{
+			Response: resp.Ok(),
+			Url:      link.URL,
+			Alias:    link.Alias,
+			ShortURL: shortURL.Build(r, workspace.DomainFromContext(r.Context()), link.Alias),
+			State:    link.State,
+
+			ActiveFrom:  link.ActiveFrom,
+			ActiveUntil: link.ActiveUntil,
+		})
+	}
+}
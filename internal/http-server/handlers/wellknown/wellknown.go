@@ -0,0 +1,80 @@
+package wellknown
+
+import (
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+// AppLinks identifies the mobile apps allowed to open short links
+// directly, as iOS universal links and Android app links.
+type AppLinks struct {
+	// AppleAppIDs are "<team id>.<bundle id>" identifiers.
+	AppleAppIDs []string
+	// ApplePaths are the paths that open the app, e.g. "/app/*". They
+	// must be given: claiming every path would send links that have
+	// nothing to do with the app to it.
+	ApplePaths          []string
+	AndroidPackage      string
+	AndroidFingerprints []string
+}
+
+type aasa struct {
+	AppLinks aasaAppLinks `json:"applinks"`
+}
+
+type aasaAppLinks struct {
+	Apps    []string     `json:"apps"`
+	Details []aasaDetail `json:"details"`
+}
+
+type aasaDetail struct {
+	AppID string   `json:"appID"`
+	Paths []string `json:"paths"`
+}
+
+type assetLink struct {
+	Relation []string    `json:"relation"`
+	Target   assetTarget `json:"target"`
+}
+
+type assetTarget struct {
+	Namespace    string   `json:"namespace"`
+	PackageName  string   `json:"package_name"`
+	Fingerprints []string `json:"sha256_cert_fingerprints"`
+}
+
+// AppleAppSiteAssociation serves /.well-known/apple-app-site-association
+// for every short domain.
+func AppleAppSiteAssociation(log *slog.Logger, links AppLinks) http.HandlerFunc {
+	doc := aasa{AppLinks: aasaAppLinks{Apps: []string{}}}
+	for _, id := range links.AppleAppIDs {
+		doc.AppLinks.Details = append(doc.AppLinks.Details, aasaDetail{AppID: id, Paths: links.ApplePaths})
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.wellknown.AppleAppSiteAssociation"
+		log.Debug("serving apple-app-site-association", slog.String("operation", op), slog.String("host", r.Host))
+
+		render.JSON(w, r, doc)
+	}
+}
+
+// AssetLinks serves /.well-known/assetlinks.json for every short domain.
+func AssetLinks(log *slog.Logger, links AppLinks) http.HandlerFunc {
+	doc := []assetLink{{
+		Relation: []string{"delegate_permission/common.handle_all_urls"},
+		Target: assetTarget{
+			Namespace:    "android_app",
+			PackageName:  links.AndroidPackage,
+			Fingerprints: links.AndroidFingerprints,
+		},
+	}}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.wellknown.AssetLinks"
+		log.Debug("serving assetlinks.json", slog.String("operation", op), slog.String("host", r.Host))
+
+		render.JSON(w, r, doc)
+	}
+}
@@ -0,0 +1,120 @@
+package create
+
+import (
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/apikey"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+)
+
+type WorkspaceCreator interface {
+	CreateWorkspace(ws storage.Workspace, hosts []string) (storage.Workspace, error)
+	CreateAPIKey(workspaceID int64, name string, keyHash string) error
+}
+
+type Request struct {
+	Name         string   `json:"name" validate:"required,max=128"`
+	Slug         string   `json:"slug" validate:"required,max=64,hostname_rfc1123"`
+	AliasLength  int      `json:"alias_length,omitempty" validate:"omitempty,min=1,max=64"`
+	RedirectCode int      `json:"redirect_code,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+	Hosts        []string `json:"hosts,omitempty" validate:"dive,hostname_rfc1123"`
+}
+
+type Response struct {
+	resp.Response
+	ID           int64  `json:"id,omitempty"`
+	Slug         string `json:"slug,omitempty"`
+	AliasLength  int    `json:"alias_length,omitempty"`
+	RedirectCode int    `json:"redirect_code,omitempty"`
+	APIKey       string `json:"api_key,omitempty"`
+}
+
+func New(log *slog.Logger, creator WorkspaceCreator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.workspace.create.New"
+		log = log.With(slog.String("operation", op))
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			errors.As(err, &validationErrors)
+
+			log.Error("failed to validate request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validationErrors))
+			return
+		}
+
+		redirectCode := req.RedirectCode
+		if redirectCode == 0 {
+			redirectCode = http.StatusFound
+		}
+
+		hosts := make([]string, 0, len(req.Hosts))
+		for _, h := range req.Hosts {
+			hosts = append(hosts, workspace.NormalizeHost(h))
+		}
+
+		ws, err := creator.CreateWorkspace(storage.Workspace{
+			Name:         req.Name,
+			Slug:         req.Slug,
+			AliasLength:  req.AliasLength,
+			RedirectCode: redirectCode,
+		}, hosts)
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceExists) {
+				log.Info("workspace already exists", slog.String("slug", req.Slug))
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, resp.Error("workspace already exists"))
+				return
+			}
+			if errors.Is(err, storage.ErrDomainExists) {
+				log.Info("host already bound to a workspace", sl.Err(err))
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, resp.Error("host already bound to a workspace"))
+				return
+			}
+			log.Error("failed to create workspace", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to create workspace"))
+			return
+		}
+
+		key, hash, err := apikey.Generate()
+		if err == nil {
+			err = creator.CreateAPIKey(ws.ID, "default", hash)
+		}
+		if err != nil {
+			log.Error("failed to issue api key", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to issue api key"))
+			return
+		}
+
+		log.Info("workspace created", slog.String("slug", ws.Slug), slog.Int64("id", ws.ID))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{
+			Response:     resp.Ok(),
+			ID:           ws.ID,
+			Slug:         ws.Slug,
+			AliasLength:  ws.AliasLength,
+			RedirectCode: ws.RedirectCode,
+			APIKey:       key,
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package get
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+)
+
+type WorkspaceGetter interface {
+	GetWorkspace(slug string) (storage.Workspace, error)
+	ListMembers(workspaceID int64) ([]storage.Member, error)
+}
+
+type Member struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type Response struct {
+	resp.Response
+	ID           int64    `json:"id,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Slug         string   `json:"slug,omitempty"`
+	AliasLength  int      `json:"alias_length,omitempty"`
+	RedirectCode int      `json:"redirect_code,omitempty"`
+	Members      []Member `json:"members"`
+}
+
+func New(log *slog.Logger, getter WorkspaceGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.workspace.get.New"
+		log = log.With(slog.String("operation", op))
+
+		slug := chi.URLParam(r, "slug")
+
+		ws, err := getter.GetWorkspace(slug)
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceNotFound) {
+				log.Info("workspace not found", slog.String("slug", slug))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("workspace not found"))
+				return
+			}
+			log.Error("failed to get workspace", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to get workspace"))
+			return
+		}
+
+		members, err := getter.ListMembers(ws.ID)
+		if err != nil {
+			log.Error("failed to list members", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to get workspace"))
+			return
+		}
+
+		out := make([]Member, 0, len(members))
+		for _, m := range members {
+			out = append(out, Member{Email: m.Email, Role: m.Role})
+		}
+
+		render.JSON(w, r, Response{
+			Response:     resp.Ok(),
+			ID:           ws.ID,
+			Name:         ws.Name,
+			Slug:         ws.Slug,
+			AliasLength:  ws.AliasLength,
+			RedirectCode: ws.RedirectCode,
+			Members:      out,
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package key
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/apikey"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+type KeyIssuer interface {
+	GetWorkspace(slug string) (storage.Workspace, error)
+	CreateAPIKey(workspaceID int64, name string, keyHash string) error
+}
+
+type Request struct {
+	Name string `json:"name,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	APIKey string `json:"api_key,omitempty"`
+}
+
+// New issues an additional API key for a workspace. The plain key is
+// only ever returned in this response.
+func New(log *slog.Logger, issuer KeyIssuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.workspace.key.New"
+		log = log.With(slog.String("operation", op))
+
+		slug := chi.URLParam(r, "slug")
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil && !errors.Is(err, io.EOF) {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		ws, err := issuer.GetWorkspace(slug)
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceNotFound) {
+				log.Info("workspace not found", slog.String("slug", slug))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("workspace not found"))
+				return
+			}
+			log.Error("failed to get workspace", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to issue api key"))
+			return
+		}
+
+		plain, hash, err := apikey.Generate()
+		if err == nil {
+			err = issuer.CreateAPIKey(ws.ID, req.Name, hash)
+		}
+		if err != nil {
+			log.Error("failed to issue api key", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to issue api key"))
+			return
+		}
+
+		log.Info("api key issued", slog.String("slug", slug))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{
+			Response: resp.Ok(),
+			APIKey:   plain,
+		})
+	}
+}
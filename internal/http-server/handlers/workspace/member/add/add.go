@@ -0,0 +1,85 @@
+package add
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type MemberAdder interface {
+	GetWorkspace(slug string) (storage.Workspace, error)
+	AddMember(workspaceID int64, member storage.Member) error
+}
+
+type Request struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=owner admin member viewer"`
+}
+
+type Response struct {
+	resp.Response
+}
+
+func New(log *slog.Logger, adder MemberAdder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.workspace.member.add.New"
+		log = log.With(slog.String("operation", op))
+
+		slug := chi.URLParam(r, "slug")
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			errors.As(err, &validationErrors)
+
+			log.Error("failed to validate request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validationErrors))
+			return
+		}
+
+		ws, err := adder.GetWorkspace(slug)
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceNotFound) {
+				log.Info("workspace not found", slog.String("slug", slug))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("workspace not found"))
+				return
+			}
+			log.Error("failed to get workspace", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to add member"))
+			return
+		}
+
+		err = adder.AddMember(ws.ID, storage.Member{
+			Email: strings.ToLower(req.Email),
+			Role:  req.Role,
+		})
+		if err != nil {
+			log.Error("failed to add member", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to add member"))
+			return
+		}
+
+		log.Info("member added", slog.String("slug", slug))
+
+		render.JSON(w, r, Response{Response: resp.Ok()})
+	}
+}
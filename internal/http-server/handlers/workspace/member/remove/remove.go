@@ -0,0 +1,71 @@
+package remove
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type MemberRemover interface {
+	GetWorkspace(slug string) (storage.Workspace, error)
+	RemoveMember(workspaceID int64, email string) error
+}
+
+type Response struct {
+	resp.Response
+}
+
+func New(log *slog.Logger, remover MemberRemover) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.workspace.member.remove.New"
+		log = log.With(slog.String("operation", op))
+
+		slug := chi.URLParam(r, "slug")
+		// The email travels in the query string: URLFormat would otherwise
+		// treat its domain suffix as a response format extension.
+		email := strings.ToLower(r.URL.Query().Get("email"))
+		if email == "" {
+			log.Info("missing email")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("query parameter email is required"))
+			return
+		}
+
+		ws, err := remover.GetWorkspace(slug)
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceNotFound) {
+				log.Info("workspace not found", slog.String("slug", slug))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("workspace not found"))
+				return
+			}
+			log.Error("failed to get workspace", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to remove member"))
+			return
+		}
+
+		if err := remover.RemoveMember(ws.ID, email); err != nil {
+			if errors.Is(err, storage.ErrMemberNotFound) {
+				log.Info("member not found")
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("member not found"))
+				return
+			}
+			log.Error("failed to remove member", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to remove member"))
+			return
+		}
+
+		log.Info("member removed", slog.String("slug", slug))
+
+		render.JSON(w, r, Response{Response: resp.Ok()})
+	}
+}
@@ -0,0 +1,143 @@
+package update
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/api/validate"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"net/http"
+)
+
+type SettingsUpdater interface {
+	GetWorkspace(slug string) (storage.Workspace, error)
+	CaseCollisions(workspaceID int64) ([]storage.AliasRef, error)
+	UpdateWorkspaceSettings(slug string, settings storage.Workspace) (storage.Workspace, error)
+}
+
+type Request struct {
+	AliasLength     int  `json:"alias_length" validate:"min=0,max=64"`
+	RedirectCode    int  `json:"redirect_code" validate:"required,oneof=301 302 307 308"`
+	CaseInsensitive bool `json:"case_insensitive"`
+}
+
+type Collision struct {
+	Domain string `json:"domain,omitempty"`
+	Alias  string `json:"alias"`
+}
+
+type Response struct {
+	resp.Response
+	Slug            string      `json:"slug,omitempty"`
+	AliasLength     int         `json:"alias_length"`
+	RedirectCode    int         `json:"redirect_code,omitempty"`
+	CaseInsensitive bool        `json:"case_insensitive"`
+	Collisions      []Collision `json:"collisions,omitempty"`
+}
+
+func New(log *slog.Logger, updater SettingsUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.workspace.update.New"
+		log = log.With(slog.String("operation", op))
+
+		slug := chi.URLParam(r, "slug")
+
+		var req Request
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("failed to parse request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			var validationErrors validator.ValidationErrors
+			errors.As(err, &validationErrors)
+
+			log.Error("failed to validate request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ValidationError(validationErrors))
+			return
+		}
+
+		current, err := updater.GetWorkspace(slug)
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceNotFound) {
+				log.Info("workspace not found", slog.String("slug", slug))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("workspace not found"))
+				return
+			}
+			log.Error("failed to get workspace", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to update workspace settings"))
+			return
+		}
+
+		if req.CaseInsensitive && !current.CaseInsensitive {
+			collisions, err := updater.CaseCollisions(current.ID)
+			if err != nil {
+				log.Error("failed to check case collisions", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("failed to update workspace settings"))
+				return
+			}
+			if len(collisions) > 0 {
+				log.Info("case collisions block case-insensitive mode", slog.Int("collisions", len(collisions)))
+				responseCollisions(w, r, collisions)
+				return
+			}
+		}
+
+		ws, err := updater.UpdateWorkspaceSettings(slug, storage.Workspace{
+			AliasLength:     req.AliasLength,
+			RedirectCode:    req.RedirectCode,
+			CaseInsensitive: req.CaseInsensitive,
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrWorkspaceNotFound) {
+				log.Info("workspace not found", slog.String("slug", slug))
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("workspace not found"))
+				return
+			}
+			if errors.Is(err, storage.ErrCaseCollision) {
+				log.Info("case collision while folding aliases", sl.Err(err))
+				render.Status(r, http.StatusConflict)
+				render.JSON(w, r, resp.Error("existing aliases differ only in case"))
+				return
+			}
+			log.Error("failed to update workspace settings", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("failed to update workspace settings"))
+			return
+		}
+
+		log.Info("workspace settings updated", slog.String("slug", slug))
+
+		render.JSON(w, r, Response{
+			Response:        resp.Ok(),
+			Slug:            ws.Slug,
+			AliasLength:     ws.AliasLength,
+			RedirectCode:    ws.RedirectCode,
+			CaseInsensitive: ws.CaseInsensitive,
+		})
+	}
+}
+
+func responseCollisions(w http.ResponseWriter, r *http.Request, refs []storage.AliasRef) {
+	collisions := make([]Collision, 0, len(refs))
+	for _, ref := range refs {
+		collisions = append(collisions, Collision{Domain: ref.Domain, Alias: ref.Alias})
+	}
+
+	render.Status(r, http.StatusConflict)
+	render.JSON(w, r, Response{
+		Response:   resp.Error("existing aliases differ only in case"),
+		Collisions: collisions,
+	})
+}
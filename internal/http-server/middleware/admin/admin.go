@@ -0,0 +1,38 @@
+package admin
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"crypto/subtle"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// New guards admin routes with a static bearer token. An empty token
+// disables the admin API altogether.
+func New(log *slog.Logger, token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/admin"),
+		)
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, resp.Error("admin api is disabled"))
+				return
+			}
+
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				log.Warn("unauthorized admin request", slog.String("path", r.URL.Path))
+				render.Status(r, http.StatusUnauthorized)
+				render.JSON(w, r, resp.Error("unauthorized"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,275 @@
+package forwarded
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type ctxKey struct{}
+
+// hop is one proxy's account of the request it received, as recorded in
+// a Forwarded element or in the X-Forwarded-* headers.
+type hop struct {
+	ip    net.IP
+	proto string
+	host  string
+}
+
+// New determines the client address, scheme and host of each request.
+// Forwarded (RFC 7239) and X-Forwarded-For/-Proto/-Host are only
+// believed when the request comes from one of the trusted proxies, and
+// then only up to the first address that is not itself trusted, so
+// clients cannot spoof them by sending the headers themselves.
+//
+// The scheme and host are written to r.URL.Scheme and r.Host so that
+// everything downstream sees what the client asked for; the address is
+// available through ClientIP, r.RemoteAddr stays the peer's.
+func New(log *slog.Logger, trusted []*net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/forwarded"),
+		)
+		log.Info("trusted proxies configured", slog.Int("count", len(trusted)))
+
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			client := hop{ip: peer(r)}
+			if isTrusted(trusted, client.ip) {
+				client = fromHeaders(trusted, client, r.Header)
+			}
+
+			if client.proto != "" {
+				r.URL.Scheme = client.proto
+			}
+			if client.host != "" {
+				r.Host = client.host
+			}
+
+			ctx := context.WithValue(r.Context(), ctxKey{}, client.ip)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ClientIP returns the client address New determined, or the peer
+// address if the middleware is not installed. It is nil if the address
+// cannot be parsed.
+func ClientIP(r *http.Request) net.IP {
+	if ip, ok := r.Context().Value(ctxKey{}).(net.IP); ok {
+		return ip
+	}
+	return peer(r)
+}
+
+// Scheme returns the scheme the client used: the one New determined, or
+// what the connection itself says.
+func Scheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// ParseTrusted parses a list of CIDR ranges; single addresses are taken
+// as ranges of one.
+func ParseTrusted(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func peer(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func isTrusted(trusted []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromHeaders returns the client as described by the proxy chain in
+// front of a trusted peer. Forwarded wins over the X-Forwarded-* headers
+// when present.
+func fromHeaders(trusted []*net.IPNet, peer hop, header http.Header) hop {
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		return walk(trusted, peer, parseForwarded(values))
+	}
+
+	var hops []hop
+	for _, s := range list(header.Values("X-Forwarded-For")) {
+		hops = append(hops, hop{ip: net.ParseIP(s)})
+	}
+
+	// X-Forwarded-Proto and -Host carry no addresses. Each proxy appends
+	// to them along with X-Forwarded-For, so their entries are matched
+	// to its entries from the nearest back; those from before the
+	// client that walk stops at are then never used.
+	protos := list(header.Values("X-Forwarded-Proto"))
+	hosts := list(header.Values("X-Forwarded-Host"))
+	for i := range hops {
+		if j := len(protos) - len(hops) + i; j >= 0 {
+			hops[i].proto = validProto(protos[j])
+		}
+		if j := len(hosts) - len(hops) + i; j >= 0 {
+			hops[i].host = validHost(hosts[j])
+		}
+	}
+	client := walk(trusted, peer, hops)
+
+	// Lists shorter than X-Forwarded-For cannot be matched, but if every
+	// hop is a trusted proxy, so is whoever wrote them.
+	if allTrusted(trusted, hops) {
+		if client.proto == "" && len(protos) > 0 {
+			client.proto = validProto(protos[0])
+		}
+		if client.host == "" && len(hosts) > 0 {
+			client.host = validHost(hosts[0])
+		}
+	}
+	return client
+}
+
+func allTrusted(trusted []*net.IPNet, hops []hop) bool {
+	for _, h := range hops {
+		if !isTrusted(trusted, h.ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// walk goes through hops from the nearest back and returns the first one
+// whose address is not a trusted proxy. If every hop is trusted, the
+// farthest one is the client.
+func walk(trusted []*net.IPNet, client hop, hops []hop) hop {
+	for i := len(hops) - 1; i >= 0; i-- {
+		if hops[i].ip == nil {
+			// Whatever came before a malformed or obfuscated entry cannot
+			// be trusted.
+			break
+		}
+		client = hops[i]
+		if !isTrusted(trusted, client.ip) {
+			break
+		}
+	}
+	return client
+}
+
+// parseForwarded parses Forwarded header values into one hop per
+// element, e.g. `for=192.0.2.60;proto=https;host=sho.rt, for="[2001:db8::1]:4711"`.
+func parseForwarded(values []string) []hop {
+	var hops []hop
+	for _, v := range values {
+		for _, element := range splitQuoted(v, ',') {
+			var h hop
+			for _, pair := range splitQuoted(element, ';') {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				value = strings.Trim(value, `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					h.ip = parseNode(value)
+				case "proto":
+					h.proto = validProto(value)
+				case "host":
+					h.host = validHost(value)
+				}
+			}
+			hops = append(hops, h)
+		}
+	}
+	return hops
+}
+
+// parseNode parses the address of a "for" node, which may carry a port
+// and brackets; "unknown" and obfuscated identifiers give nil.
+func parseNode(node string) net.IP {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	return net.ParseIP(strings.Trim(node, "[]"))
+}
+
+// splitQuoted splits s on sep outside of double quotes.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// list splits comma-separated header values into their entries.
+func list(values []string) []string {
+	var entries []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			entries = append(entries, strings.TrimSpace(s))
+		}
+	}
+	return entries
+}
+
+func validProto(proto string) string {
+	switch proto = strings.ToLower(proto); proto {
+	case "http", "https":
+		return proto
+	}
+	return ""
+}
+
+func validHost(host string) string {
+	if host == "" || strings.ContainsAny(host, "/\\?#@ \t") {
+		return ""
+	}
+	return host
+}
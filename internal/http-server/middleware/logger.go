@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"URL-Shortener/internal/http-server/middleware/forwarded"
 	"github.com/go-chi/chi/v5/middleware"
 	"log/slog"
 	"net/http"
@@ -17,7 +18,8 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 			entry := log.With(
 				slog.String("method", r.Method),
 				slog.String("path", r.URL.Path),
-				slog.String("remote", r.RemoteAddr),
+				slog.String("remote", forwarded.ClientIP(r).String()),
+				slog.String("peer", r.RemoteAddr),
 				slog.String("user_agent", r.UserAgent()),
 				slog.String("request_id", middleware.GetReqID(r.Context())),
 			)
@@ -0,0 +1,179 @@
+package workspace
+
+import (
+	resp "URL-Shortener/internal/lib/api/response"
+	"URL-Shortener/internal/lib/apikey"
+	"URL-Shortener/internal/lib/logger/sl"
+	"URL-Shortener/internal/storage"
+	"context"
+	"errors"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	// domainHeader lets API clients address links bound to a short
+	// domain other than the host serving the API.
+	domainHeader = "X-Short-Domain"
+)
+
+var (
+	errMissingAPIKey = errors.New("missing api key")
+	errInvalidAPIKey = errors.New("invalid api key")
+	errForeignDomain = errors.New("domain does not belong to workspace")
+)
+
+type ctxKey struct{}
+
+type resolved struct {
+	workspace storage.Workspace
+	domain    storage.Domain
+}
+
+type Resolver interface {
+	WorkspaceByAPIKey(keyHash string) (storage.Workspace, error)
+	WorkspaceByID(id int64) (storage.Workspace, error)
+	DefaultWorkspace() (storage.Workspace, error)
+	GetDomain(host string) (storage.Domain, error)
+}
+
+// New resolves the workspace and short domain of a public request from
+// its Host header alone and stores them in the request context. Hosts
+// that are not registered fall back to the default workspace.
+func New(log *slog.Logger, resolver Resolver) func(next http.Handler) http.Handler {
+	return middleware(log, resolver, resolveHost)
+}
+
+// Authenticated resolves the workspace of an API request from its API
+// key, which it requires. The short domain is taken from the
+// X-Short-Domain header, or else the Host header, and only used if it
+// belongs to that workspace; the Host header never selects a workspace.
+func Authenticated(log *slog.Logger, resolver Resolver) func(next http.Handler) http.Handler {
+	return middleware(log, resolver, resolveKey)
+}
+
+func middleware(log *slog.Logger, resolver Resolver, resolve func(*http.Request, Resolver) (resolved, error)) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		log := log.With(
+			slog.String("component", "middleware/workspace"),
+		)
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			res, err := resolve(r, resolver)
+			if err != nil {
+				switch {
+				case errors.Is(err, errMissingAPIKey):
+					log.Info("missing api key")
+					render.Status(r, http.StatusUnauthorized)
+					render.JSON(w, r, resp.Error("api key required"))
+				case errors.Is(err, errInvalidAPIKey):
+					log.Info("unknown api key")
+					render.Status(r, http.StatusUnauthorized)
+					render.JSON(w, r, resp.Error("invalid api key"))
+				case errors.Is(err, errForeignDomain):
+					log.Info("domain outside of workspace", slog.String("domain", r.Header.Get(domainHeader)))
+					render.Status(r, http.StatusBadRequest)
+					render.JSON(w, r, resp.Error("unknown domain"))
+				default:
+					log.Error("failed to resolve workspace", sl.Err(err))
+					render.Status(r, http.StatusInternalServerError)
+					render.JSON(w, r, resp.Error("internal error"))
+				}
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ctxKey{}, res)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func resolveHost(r *http.Request, resolver Resolver) (resolved, error) {
+	var res resolved
+
+	domain, err := resolver.GetDomain(NormalizeHost(r.Host))
+	if errors.Is(err, storage.ErrDomainNotFound) {
+		res.workspace, err = resolver.DefaultWorkspace()
+		return res, err
+	}
+	if err != nil {
+		return res, err
+	}
+
+	res.domain = domain
+	res.workspace, err = resolver.WorkspaceByID(domain.WorkspaceID)
+	return res, err
+}
+
+func resolveKey(r *http.Request, resolver Resolver) (resolved, error) {
+	var res resolved
+
+	key := apiKey(r)
+	if key == "" {
+		return res, errMissingAPIKey
+	}
+
+	var err error
+	res.workspace, err = resolver.WorkspaceByAPIKey(apikey.Hash(key))
+	if errors.Is(err, storage.ErrWorkspaceNotFound) {
+		return res, errInvalidAPIKey
+	}
+	if err != nil {
+		return res, err
+	}
+
+	host := NormalizeHost(r.Host)
+	if h := r.Header.Get(domainHeader); h != "" {
+		host = NormalizeHost(h)
+	}
+
+	domain, err := resolver.GetDomain(host)
+	if err != nil && !errors.Is(err, storage.ErrDomainNotFound) {
+		return res, err
+	}
+	if err == nil && domain.WorkspaceID == res.workspace.ID {
+		res.domain = domain
+	} else if r.Header.Get(domainHeader) != "" {
+		return res, errForeignDomain
+	}
+	return res, nil
+}
+
+func apiKey(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(apiKeyHeader))
+}
+
+// NormalizeHost lowercases host and strips any port from it.
+func NormalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// FromContext returns the workspace resolved by New or Authenticated.
+func FromContext(ctx context.Context) storage.Workspace {
+	res, _ := ctx.Value(ctxKey{}).(resolved)
+	return res.workspace
+}
+
+// DomainFromContext returns the registered short domain the request was
+// addressed to, or the zero Domain if there is none.
+func DomainFromContext(ctx context.Context) storage.Domain {
+	res, _ := ctx.Value(ctxKey{}).(resolved)
+	return res.domain
+}
+
+// Scope returns the storage scope for the request's workspace and domain.
+func Scope(r *http.Request) storage.Scope {
+	res, _ := r.Context().Value(ctxKey{}).(resolved)
+	return storage.Scope{
+		WorkspaceID:     res.workspace.ID,
+		Domain:          res.domain.Host,
+		CaseInsensitive: res.workspace.CaseInsensitive,
+	}
+}
@@ -0,0 +1,522 @@
+package workspace_test
+
+import (
+	"URL-Shortener/internal/http-server/handlers/alias/availability"
+	"URL-Shortener/internal/http-server/handlers/alias/suggest"
+	del "URL-Shortener/internal/http-server/handlers/url/delete"
+	"URL-Shortener/internal/http-server/handlers/url/get"
+	"URL-Shortener/internal/http-server/handlers/url/list"
+	"URL-Shortener/internal/http-server/handlers/url/save"
+	"URL-Shortener/internal/http-server/handlers/url/search"
+	"URL-Shortener/internal/http-server/middleware/workspace"
+	"URL-Shortener/internal/lib/alias"
+	"URL-Shortener/internal/lib/apikey"
+	"URL-Shortener/internal/lib/random"
+	"URL-Shortener/internal/lib/shorturl"
+	"URL-Shortener/internal/storage"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	acmeKey   = "us_acme"
+	globexKey = "us_globex"
+)
+
+type linkKey struct {
+	workspaceID int64
+	domain      string
+	alias       string
+}
+
+// memStorage keeps links in memory, strictly by the scope it is given,
+// so that the tests see exactly the scope the middleware resolved.
+type memStorage struct {
+	mu         sync.Mutex
+	keys       map[string]int64
+	workspaces map[int64]storage.Workspace
+	domains    map[string]storage.Domain
+	links      map[linkKey]storage.Link
+}
+
+func newMemStorage() *memStorage {
+	s := &memStorage{
+		keys: map[string]int64{
+			apikey.Hash(acmeKey):   1,
+			apikey.Hash(globexKey): 2,
+		},
+		workspaces: map[int64]storage.Workspace{
+			1: {ID: 1, Slug: "acme"},
+			2: {ID: 2, Slug: "globex"},
+			3: {ID: 3, Slug: storage.DefaultWorkspaceSlug},
+		},
+		domains: map[string]storage.Domain{
+			"acme.link":   {Host: "acme.link", WorkspaceID: 1},
+			"globex.link": {Host: "globex.link", WorkspaceID: 2},
+		},
+		links: map[linkKey]storage.Link{},
+	}
+
+	s.add(1, "acme.link", "promo", "https://acme.example/promo")
+	s.add(1, "", "docs", "https://acme.example/docs")
+	s.add(2, "globex.link", "promo", "https://globex.example/promo")
+	s.add(2, "globex.link", "promotion", "https://globex.example/promotion")
+	s.add(2, "", "docs", "https://globex.example/docs")
+	return s
+}
+
+func (s *memStorage) add(workspaceID int64, domain string, alias string, url string) {
+	s.links[linkKey{workspaceID, domain, alias}] = storage.Link{
+		Domain: domain,
+		Alias:  alias,
+		URL:    url,
+		Title:  alias + " of " + s.workspaces[workspaceID].Slug,
+		State:  storage.StateActive,
+	}
+}
+
+func (s *memStorage) has(workspaceID int64, domain string, alias string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.links[linkKey{workspaceID, domain, alias}]
+	return ok
+}
+
+func (s *memStorage) WorkspaceByAPIKey(keyHash string) (storage.Workspace, error) {
+	id, ok := s.keys[keyHash]
+	if !ok {
+		return storage.Workspace{}, storage.ErrWorkspaceNotFound
+	}
+	return s.workspaces[id], nil
+}
+
+func (s *memStorage) WorkspaceByID(id int64) (storage.Workspace, error) {
+	ws, ok := s.workspaces[id]
+	if !ok {
+		return storage.Workspace{}, storage.ErrWorkspaceNotFound
+	}
+	return ws, nil
+}
+
+func (s *memStorage) DefaultWorkspace() (storage.Workspace, error) {
+	return s.workspaces[3], nil
+}
+
+func (s *memStorage) GetDomain(host string) (storage.Domain, error) {
+	d, ok := s.domains[host]
+	if !ok {
+		return storage.Domain{}, storage.ErrDomainNotFound
+	}
+	return d, nil
+}
+
+func (s *memStorage) SaveURL(scope storage.Scope, link storage.Link) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := linkKey{scope.WorkspaceID, scope.Domain, link.Alias}
+	if _, ok := s.links[key]; ok {
+		return 0, storage.ErrAliasExists
+	}
+	link.Domain = scope.Domain
+	s.links[key] = link
+	return int64(len(s.links)), nil
+}
+
+func (s *memStorage) AliasExists(scope storage.Scope, alias string) (bool, error) {
+	return s.has(scope.WorkspaceID, scope.Domain, alias), nil
+}
+
+func (s *memStorage) GetLink(scope storage.Scope, alias string) (storage.Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[linkKey{scope.WorkspaceID, scope.Domain, alias}]
+	if !ok {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+	return link, nil
+}
+
+func (s *memStorage) DeleteUrl(scope storage.Scope, alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := linkKey{scope.WorkspaceID, scope.Domain, alias}
+	if _, ok := s.links[key]; !ok {
+		return storage.ErrUrlNotFound
+	}
+	delete(s.links, key)
+	return nil
+}
+
+// inScope returns the links of scope's domain or, if allDomains is set,
+// of every domain of its workspace, ordered by alias.
+func (s *memStorage) inScope(scope storage.Scope, allDomains bool) []storage.Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var links []storage.Link
+	for key, link := range s.links {
+		if key.workspaceID == scope.WorkspaceID && (allDomains || key.domain == scope.Domain) {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Alias < links[j].Alias })
+	return links
+}
+
+func (s *memStorage) ListURLs(scope storage.Scope, prefix string, limit int, offset int) ([]storage.Link, error) {
+	var links []storage.Link
+	for _, link := range s.inScope(scope, false) {
+		if strings.HasPrefix(link.Alias, prefix) {
+			links = append(links, link)
+		}
+	}
+	links = links[min(offset, len(links)):]
+	return links[:min(limit, len(links))], nil
+}
+
+func (s *memStorage) Search(scope storage.Scope, query string, limit int) ([]storage.SearchResult, error) {
+	var results []storage.SearchResult
+	for _, link := range s.inScope(scope, true) {
+		if strings.Contains(link.Alias, query) || strings.Contains(link.Title, query) {
+			results = append(results, storage.SearchResult{Link: link})
+		}
+	}
+	return results[:min(limit, len(results))], nil
+}
+
+func (s *memStorage) SimilarAliases(scope storage.Scope, query string, limit int, public bool) ([]string, error) {
+	var aliases []string
+	for _, link := range s.inScope(scope, false) {
+		aliases = append(aliases, link.Alias)
+	}
+	return aliases[:min(limit, len(aliases))], nil
+}
+
+func (s *memStorage) TakenAliases(scope storage.Scope, aliases []string) (map[string]error, error) {
+	taken := map[string]error{}
+	for _, a := range aliases {
+		if s.has(scope.WorkspaceID, scope.Domain, a) {
+			taken[a] = storage.ErrAliasExists
+		}
+	}
+	return taken, nil
+}
+
+// newAPI mounts the workspace-scoped API the way main does.
+func newAPI(t *testing.T, s *memStorage) http.Handler {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	policy, err := alias.NewPolicy(alias.PolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := random.NewGenerator(random.ModeLetters, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shortURL := shorturl.New("http://api.example")
+
+	r := chi.NewRouter()
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(workspace.Authenticated(log, s))
+
+		r.Post("/url", save.New(log, s, policy, gen, shortURL, 6, 5))
+		r.Get("/url/*", get.New(log, s, shortURL))
+		r.Delete("/url/*", del.New(log, s))
+		r.Get("/urls", list.New(log, s, shortURL))
+		r.Get("/urls/search", search.New(log, s, shortURL))
+		r.Get("/aliases/suggest", suggest.New(log, s, shortURL))
+		r.Get("/aliases/{alias}/availability", availability.New(log, s, policy, gen, 6))
+	})
+	return r
+}
+
+type call struct {
+	method string
+	path   string
+	body   string
+}
+
+// calls reaches every endpoint once, each aimed at the alias "promo".
+var calls = []call{
+	{http.MethodPost, "/api/v1/url", `{"alias":"promo","url":"https://evil.example"}`},
+	{http.MethodGet, "/api/v1/url/promo", ""},
+	{http.MethodDelete, "/api/v1/url/promo", ""},
+	{http.MethodGet, "/api/v1/urls", ""},
+	{http.MethodGet, "/api/v1/urls/search?q=promo", ""},
+	{http.MethodGet, "/api/v1/aliases/suggest?q=promo", ""},
+	{http.MethodGet, "/api/v1/aliases/promo/availability", ""},
+}
+
+type client struct {
+	host   string
+	key    string
+	domain string
+}
+
+func (c client) do(t *testing.T, api http.Handler, method string, path string, body string) (int, map[string]any) {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Host = c.host
+	if r.Host == "" {
+		r.Host = "api.example"
+	}
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if c.key != "" {
+		r.Header.Set("X-API-Key", c.key)
+	}
+	if c.domain != "" {
+		r.Header.Set("X-Short-Domain", c.domain)
+	}
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("%s %s: decode %q: %v", method, path, w.Body.String(), err)
+	}
+	return w.Code, out
+}
+
+func TestAPIRequiresKey(t *testing.T) {
+	s := newMemStorage()
+	api := newAPI(t, s)
+
+	clients := map[string]client{
+		"no key, domain header": {domain: "acme.link"},
+		"no key, host":          {host: "acme.link"},
+		"unknown key":           {key: "us_unknown", domain: "acme.link"},
+	}
+	for name, c := range clients {
+		for _, call := range calls {
+			if code, _ := c.do(t, api, call.method, call.path, call.body); code != http.StatusUnauthorized {
+				t.Errorf("%s: %s %s: got %d, want %d", name, call.method, call.path, code, http.StatusUnauthorized)
+			}
+		}
+	}
+
+	if !s.has(1, "acme.link", "promo") {
+		t.Error("acme's link was deleted without a key")
+	}
+	if link, _ := s.GetLink(storage.Scope{WorkspaceID: 1, Domain: "acme.link"}, "promo"); link.URL != "https://acme.example/promo" {
+		t.Errorf("acme's link was changed without a key: %q", link.URL)
+	}
+}
+
+func TestAPIRejectsForeignDomain(t *testing.T) {
+	s := newMemStorage()
+	api := newAPI(t, s)
+
+	globex := client{key: globexKey, domain: "acme.link"}
+	for _, call := range calls {
+		if code, _ := globex.do(t, api, call.method, call.path, call.body); code != http.StatusBadRequest {
+			t.Errorf("%s %s: got %d, want %d", call.method, call.path, code, http.StatusBadRequest)
+		}
+	}
+
+	if !s.has(1, "acme.link", "promo") {
+		t.Error("acme's link was deleted by globex")
+	}
+}
+
+func TestAPIHostDoesNotSelectWorkspace(t *testing.T) {
+	s := newMemStorage()
+	api := newAPI(t, s)
+
+	// globex's key on acme's host works on globex's default domain.
+	globex := client{key: globexKey, host: "acme.link"}
+
+	if code, _ := globex.do(t, api, http.MethodGet, "/api/v1/url/promo", ""); code != http.StatusNotFound {
+		t.Errorf("get: got %d, want %d", code, http.StatusNotFound)
+	}
+	if code, _ := globex.do(t, api, http.MethodDelete, "/api/v1/url/promo", ""); code != http.StatusNotFound {
+		t.Errorf("delete: got %d, want %d", code, http.StatusNotFound)
+	}
+	if code, out := globex.do(t, api, http.MethodGet, "/api/v1/url/docs", ""); code != http.StatusOK || out["url"] != "https://globex.example/docs" {
+		t.Errorf("get docs: got %d %v, want globex's link", code, out)
+	}
+	if !s.has(1, "acme.link", "promo") {
+		t.Error("acme's link was deleted by globex")
+	}
+}
+
+func TestAPIIsolatesWorkspacesAndDomains(t *testing.T) {
+	acme := client{key: acmeKey, domain: "acme.link"}
+	acmeDefault := client{key: acmeKey}
+	globex := client{key: globexKey, domain: "globex.link"}
+	globexDefault := client{key: globexKey}
+
+	t.Run("get", func(t *testing.T) {
+		api := newAPI(t, newMemStorage())
+
+		for c, want := range map[client]string{
+			acme:          "https://acme.example/promo",
+			globex:        "https://globex.example/promo",
+			acmeDefault:   "",
+			globexDefault: "",
+		} {
+			code, out := c.do(t, api, http.MethodGet, "/api/v1/url/promo", "")
+			switch {
+			case want == "" && code != http.StatusNotFound:
+				t.Errorf("%+v: got %d, want %d", c, code, http.StatusNotFound)
+			case want != "" && out["url"] != want:
+				t.Errorf("%+v: got %v, want %q", c, out["url"], want)
+			}
+		}
+	})
+
+	t.Run("save", func(t *testing.T) {
+		s := newMemStorage()
+		api := newAPI(t, s)
+
+		body := `{"alias":"promo","url":"https://new.example"}`
+		if code, _ := acme.do(t, api, http.MethodPost, "/api/v1/url", body); code != http.StatusConflict {
+			t.Errorf("acme on its domain: got %d, want %d", code, http.StatusConflict)
+		}
+		if code, _ := globexDefault.do(t, api, http.MethodPost, "/api/v1/url", body); code != http.StatusOK {
+			t.Errorf("globex on its default domain: got %d, want %d", code, http.StatusOK)
+		}
+		if code, _ := acmeDefault.do(t, api, http.MethodPost, "/api/v1/url", body); code != http.StatusOK {
+			t.Errorf("acme on its default domain: got %d, want %d", code, http.StatusOK)
+		}
+
+		for key, want := range map[linkKey]string{
+			{1, "acme.link", "promo"}:   "https://acme.example/promo",
+			{2, "globex.link", "promo"}: "https://globex.example/promo",
+			{1, "", "promo"}:            "https://new.example",
+			{2, "", "promo"}:            "https://new.example",
+		} {
+			if got := s.links[key].URL; got != want {
+				t.Errorf("%+v: got %q, want %q", key, got, want)
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s := newMemStorage()
+		api := newAPI(t, s)
+
+		if code, _ := acme.do(t, api, http.MethodDelete, "/api/v1/url/promo", ""); code != http.StatusOK {
+			t.Fatalf("got %d, want %d", code, http.StatusOK)
+		}
+		if s.has(1, "acme.link", "promo") {
+			t.Error("acme's link was not deleted")
+		}
+		if !s.has(2, "globex.link", "promo") {
+			t.Error("globex's link was deleted by acme")
+		}
+		if code, _ := globexDefault.do(t, api, http.MethodDelete, "/api/v1/url/promotion", ""); code != http.StatusNotFound {
+			t.Errorf("globex's default domain: got %d, want %d", code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		api := newAPI(t, newMemStorage())
+
+		for c, want := range map[client][]string{
+			acme:          {"https://acme.example/promo"},
+			acmeDefault:   {"https://acme.example/docs"},
+			globex:        {"https://globex.example/promo", "https://globex.example/promotion"},
+			globexDefault: {"https://globex.example/docs"},
+		} {
+			_, out := c.do(t, api, http.MethodGet, "/api/v1/urls", "")
+			if got := field(out["links"], "url"); !equal(got, want) {
+				t.Errorf("%+v: got %v, want %v", c, got, want)
+			}
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		api := newAPI(t, newMemStorage())
+
+		for c, want := range map[client][]string{
+			acme:   {"https://acme.example/promo"},
+			globex: {"https://globex.example/promo", "https://globex.example/promotion"},
+		} {
+			_, out := c.do(t, api, http.MethodGet, "/api/v1/urls/search?q=promo", "")
+			if got := field(out["results"], "url"); !equal(got, want) {
+				t.Errorf("%+v: got %v, want %v", c, got, want)
+			}
+		}
+	})
+
+	t.Run("suggest", func(t *testing.T) {
+		api := newAPI(t, newMemStorage())
+
+		for c, want := range map[client][]string{
+			acme:          {"promo"},
+			acmeDefault:   {},
+			globex:        {"promo", "promotion"},
+			globexDefault: {},
+		} {
+			_, out := c.do(t, api, http.MethodGet, "/api/v1/aliases/suggest?q=prom", "")
+			got := field(out["suggestions"], "alias")
+			sort.Strings(got)
+			if !equal(got, want) {
+				t.Errorf("%+v: got %v, want %v", c, got, want)
+			}
+		}
+	})
+
+	t.Run("availability", func(t *testing.T) {
+		api := newAPI(t, newMemStorage())
+
+		for _, tc := range []struct {
+			client client
+			alias  string
+			want   bool
+		}{
+			{acme, "promo", false},
+			{acme, "promotion", true},
+			{acmeDefault, "promo", true},
+			{globex, "promotion", false},
+			{globexDefault, "docs", false},
+			{globexDefault, "promotion", true},
+		} {
+			_, out := tc.client.do(t, api, http.MethodGet, "/api/v1/aliases/"+tc.alias+"/availability", "")
+			if out["available"] != tc.want {
+				t.Errorf("%+v %s: got %v, want %v", tc.client, tc.alias, out["available"], tc.want)
+			}
+		}
+	})
+}
+
+// field collects name from a JSON array of objects.
+func field(v any, name string) []string {
+	items, _ := v.([]any)
+	out := []string{}
+	for _, item := range items {
+		obj, _ := item.(map[string]any)
+		s, _ := obj[name].(string)
+		out = append(out, s)
+	}
+	return out
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package alias
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/text/unicode/norm"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FromPath decodes an alias taken from a URL path parameter. The router
+// hands out the raw, still percent-encoded segment whenever the client's
+// encoding differs from Go's canonical one, and browsers on some systems
+// send decomposed (NFD) text, so both are undone here. Surrounding
+// slashes of hierarchical aliases are dropped.
+func FromPath(param string) (string, error) {
+	s, err := url.PathUnescape(param)
+	if err != nil {
+		return "", fmt.Errorf("alias.FromPath: %w", err)
+	}
+	return strings.Trim(norm.NFC.String(s), "/"), nil
+}
+
+// FromRequest returns the decoded alias of a route declared either with
+// an {alias} parameter or, for hierarchical aliases, a trailing wildcard.
+func FromRequest(r *http.Request) (string, error) {
+	param := chi.URLParam(r, "alias")
+	if param == "" {
+		param = chi.URLParam(r, "*")
+	}
+	return FromPath(param)
+}
@@ -0,0 +1,263 @@
+package alias
+
+import (
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// CaseMixed keeps aliases exactly as submitted.
+	CaseMixed = "mixed"
+	// CaseLower folds aliases to lower case before they are stored.
+	CaseLower = "lower"
+	// CaseStrictLower rejects aliases that contain upper-case letters.
+	CaseStrictLower = "strict-lower"
+)
+
+const DefaultCharset = `^[a-zA-Z0-9_-]+$`
+
+// Violation describes a single policy rule an alias breaks.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// CheckCharViolation is reported for a custom alias that looks like a
+// generated one but does not carry a valid check character.
+var CheckCharViolation = Violation{
+	Rule:    "check_char",
+	Message: "alias looks like a generated alias but its check character is invalid",
+}
+
+type PolicyConfig struct {
+	MinLength int
+	MaxLength int
+	Charset   string
+	// Unicode admits aliases outside ASCII. They are normalized to NFC
+	// and checked for script mixing and Latin lookalikes.
+	Unicode bool
+	// Hierarchical admits '/'-separated aliases such as "eng/oncall", at
+	// most MaxDepth segments deep. Charset then applies to each segment.
+	Hierarchical  bool
+	MaxDepth      int
+	Case          string
+	ReservedWords []string
+	BlockedWords  []string
+}
+
+// Policy decides which custom aliases are acceptable.
+type Policy struct {
+	minLength int
+	maxLength int
+	charset   *regexp.Regexp
+	caseRule  string
+	unicode   bool
+	hierarchy bool
+	maxDepth  int
+	reserved  Reserved
+	blocked   []string
+}
+
+func NewPolicy(cfg PolicyConfig) (*Policy, error) {
+	const op = "alias.NewPolicy"
+
+	if cfg.Charset == "" {
+		cfg.Charset = DefaultCharset
+	}
+	if cfg.Unicode && cfg.Charset == DefaultCharset {
+		cfg.Charset = DefaultUnicodeCharset
+	}
+	charset, err := regexp.Compile(cfg.Charset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: charset: %w", op, err)
+	}
+
+	switch cfg.Case {
+	case "":
+		cfg.Case = CaseMixed
+	case CaseMixed, CaseLower, CaseStrictLower:
+	default:
+		return nil, fmt.Errorf("%s: unknown case rule %q", op, cfg.Case)
+	}
+
+	if cfg.MaxLength > 0 && cfg.MinLength > cfg.MaxLength {
+		return nil, fmt.Errorf("%s: min length %d exceeds max length %d", op, cfg.MinLength, cfg.MaxLength)
+	}
+
+	blocked := make([]string, 0, len(cfg.BlockedWords))
+	for _, w := range cfg.BlockedWords {
+		if w = fold(w); w != "" {
+			blocked = append(blocked, w)
+		}
+	}
+
+	return &Policy{
+		minLength: cfg.MinLength,
+		maxLength: cfg.MaxLength,
+		charset:   charset,
+		caseRule:  cfg.Case,
+		unicode:   cfg.Unicode,
+		hierarchy: cfg.Hierarchical,
+		maxDepth:  cfg.MaxDepth,
+		reserved:  NewReserved(cfg.ReservedWords),
+		blocked:   blocked,
+	}, nil
+}
+
+func (p *Policy) Reserved() Reserved {
+	return p.reserved
+}
+
+// Normalize converts alias to NFC and applies the case rule. It must be
+// called before Check so that the normalized alias is what gets
+// validated and stored.
+func (p *Policy) Normalize(alias string) string {
+	alias = norm.NFC.String(alias)
+	if p.hierarchy {
+		alias = strings.Trim(alias, "/")
+	}
+	if p.caseRule == CaseLower {
+		return strings.ToLower(alias)
+	}
+	return alias
+}
+
+// Check returns every rule alias violates, or nil if it is acceptable.
+func (p *Policy) Check(alias string) []Violation {
+	var violations []Violation
+
+	length := utf8.RuneCountInString(alias)
+	if p.minLength > 0 && length < p.minLength {
+		violations = append(violations, Violation{
+			Rule:    "min_length",
+			Message: fmt.Sprintf("alias must be at least %d characters long", p.minLength),
+		})
+	}
+	if p.maxLength > 0 && length > p.maxLength {
+		violations = append(violations, Violation{
+			Rule:    "max_length",
+			Message: fmt.Sprintf("alias must be at most %d characters long", p.maxLength),
+		})
+	}
+
+	segments := []string{alias}
+	if p.hierarchy {
+		segments = strings.Split(alias, "/")
+		violations = append(violations, p.checkSegments(segments)...)
+	}
+
+	for _, segment := range segments {
+		if segment != "" && !p.charset.MatchString(segment) {
+			violations = append(violations, Violation{
+				Rule:    "charset",
+				Message: "alias contains characters that are not allowed",
+			})
+			break
+		}
+	}
+
+	if p.unicode {
+		violations = append(violations, checkScripts(alias)...)
+	}
+
+	if p.caseRule == CaseStrictLower && strings.IndexFunc(alias, unicode.IsUpper) >= 0 {
+		violations = append(violations, Violation{
+			Rule:    "case",
+			Message: "alias must be lower case",
+		})
+	}
+
+	// Only the first segment can shadow a system route.
+	if p.reserved.Contains(segments[0]) {
+		violations = append(violations, Violation{
+			Rule:    "reserved",
+			Message: "alias is reserved",
+		})
+	}
+
+	if p.isBlocked(alias) {
+		violations = append(violations, Violation{
+			Rule:    "blocked",
+			Message: "alias contains a blocked word",
+		})
+	}
+
+	return violations
+}
+
+func (p *Policy) checkSegments(segments []string) []Violation {
+	var violations []Violation
+
+	if p.maxDepth > 0 && len(segments) > p.maxDepth {
+		violations = append(violations, Violation{
+			Rule:    "depth",
+			Message: fmt.Sprintf("alias must have at most %d segments", p.maxDepth),
+		})
+	}
+
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			violations = append(violations, Violation{
+				Rule:    "segment",
+				Message: "alias segments must not be empty, \".\" or \"..\"",
+			})
+			break
+		}
+	}
+
+	return violations
+}
+
+func (p *Policy) isBlocked(alias string) bool {
+	if len(p.blocked) == 0 {
+		return false
+	}
+
+	folded := fold(alias)
+	for _, w := range p.blocked {
+		if strings.Contains(folded, w) {
+			return true
+		}
+	}
+	return false
+}
+
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'!': 'i',
+	'3': 'e',
+	'4': 'a',
+	'@': 'a',
+	'5': 's',
+	'$': 's',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+}
+
+// fold reduces s to a canonical form for blocked word matching: lower
+// case, leetspeak digits and symbols mapped to letters, separators
+// dropped and repeated letters collapsed, so "B4d-W0rrd" matches "badword".
+func fold(s string) string {
+	var b strings.Builder
+	var last rune
+	for _, r := range strings.ToLower(s) {
+		if l, ok := leet[r]; ok {
+			r = l
+		}
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if r == last {
+			continue
+		}
+		b.WriteRune(r)
+		last = r
+	}
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package alias
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultReserved lists path segments used by the service itself. An
+// alias equal to one of them would be shadowed by, or shadow, a system
+// route now that short links are served from the site root.
+var defaultReserved = []string{
+	"admin",
+	"api",
+	"assets",
+	"favicon",
+	"health",
+	"healthz",
+	"livez",
+	"metrics",
+	"readyz",
+	"robots",
+	"sitemap",
+	"static",
+}
+
+type Reserved map[string]struct{}
+
+// NewReserved returns the built-in reserved words extended with extra.
+func NewReserved(extra []string) Reserved {
+	r := make(Reserved, len(defaultReserved)+len(extra))
+	for _, w := range defaultReserved {
+		r[w] = struct{}{}
+	}
+	for _, w := range extra {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			r[w] = struct{}{}
+		}
+	}
+	return r
+}
+
+// Contains reports whether alias is reserved. The comparison ignores case
+// so that "API" cannot be used to sidestep the list.
+func (r Reserved) Contains(alias string) bool {
+	_, ok := r[strings.ToLower(alias)]
+	return ok
+}
+
+func (r Reserved) Words() []string {
+	words := make([]string, 0, len(r))
+	for w := range r {
+		words = append(words, w)
+	}
+	sort.Strings(words)
+	return words
+}
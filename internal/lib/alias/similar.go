@@ -0,0 +1,97 @@
+package alias
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Closest returns up to n of candidates that look like what someone
+// typing target meant: those sharing a prefix with it come first, then
+// those within a small edit distance, nearest first.
+func Closest(target string, candidates []string, n int) []string {
+	type match struct {
+		alias    string
+		prefix   bool
+		distance int
+	}
+
+	folded := strings.ToLower(target)
+	maxDistance := len([]rune(folded))/3 + 1
+
+	var matches []match
+	for _, c := range candidates {
+		fc := strings.ToLower(c)
+		m := match{alias: c, distance: Distance(folded, fc)}
+		m.prefix = folded != "" && (strings.HasPrefix(fc, folded) || strings.HasPrefix(folded, fc))
+		if m.prefix || m.distance <= maxDistance {
+			matches = append(matches, m)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].prefix != matches[j].prefix {
+			return matches[i].prefix
+		}
+		return matches[i].distance < matches[j].distance
+	})
+
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	closest := make([]string, 0, len(matches))
+	for _, m := range matches {
+		closest = append(closest, m.alias)
+	}
+	return closest
+}
+
+// Distance is the Damerau-Levenshtein (optimal string alignment) distance
+// between a and b in runes, so that a swapped pair of adjacent
+// characters counts as a single typo.
+func Distance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// d[i][j] is the distance between ra[:i] and rb[:j]; only the last
+	// three rows are needed.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	return prev[len(rb)]
+}
+
+// Alternatives returns variations of a taken alias to offer instead:
+// its separators swapped or dropped, then numeric suffixes. Callers still
+// have to check them against the policy and storage.
+func Alternatives(alias string) []string {
+	var alts []string
+	if strings.Contains(alias, "-") {
+		alts = append(alts, strings.ReplaceAll(alias, "-", "_"), strings.ReplaceAll(alias, "-", ""))
+	}
+	if strings.Contains(alias, "_") {
+		alts = append(alts, strings.ReplaceAll(alias, "_", "-"), strings.ReplaceAll(alias, "_", ""))
+	}
+	for n := 2; n <= 9; n++ {
+		alts = append(alts, fmt.Sprintf("%s-%d", alias, n), fmt.Sprintf("%s%d", alias, n))
+	}
+	return alts
+}
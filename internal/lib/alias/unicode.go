@@ -0,0 +1,101 @@
+package alias
+
+import (
+	"slices"
+	"unicode"
+)
+
+// DefaultUnicodeCharset admits letters, marks and digits of any script,
+// emoji (including ZWJ sequences, variation selectors and skin tone
+// modifiers), '-' and '_'.
+const DefaultUnicodeCharset = `^[\p{L}\p{M}\p{Nd}\p{So}\p{Sk}\x{200D}\x{FE0F}_-]+$`
+
+// highlyRestrictive lists the script combinations UTS #39 allows beyond a
+// single script: Latin mixed with one of the CJK writing systems.
+var highlyRestrictive = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// latinLookalikes holds Cyrillic and Greek letters that render like Latin
+// ones. An alias written entirely in them is a whole-script confusable of
+// some Latin alias, e.g. Cyrillic "раура1" for "paypal".
+var latinLookalikes = map[rune]struct{}{}
+
+func init() {
+	for _, r := range "авекмнорстухіјѕԁԛԝүһӏАВЕКМНОРСТУХІЈЅ" + "αικνορτυχΑΒΕΖΗΙΚΜΝΟΡΤΥΧ" {
+		latinLookalikes[r] = struct{}{}
+	}
+}
+
+// checkScripts returns the UTS #39 style violations of alias: scripts
+// mixed beyond the highly restrictive profile, or a whole alias made of
+// Latin lookalikes.
+func checkScripts(alias string) []Violation {
+	seen := map[string]bool{}
+	allLookalike, letters := true, 0
+
+	for _, r := range alias {
+		script := scriptOf(r)
+		if script == "" {
+			continue
+		}
+		seen[script] = true
+
+		if unicode.IsLetter(r) {
+			letters++
+			if _, ok := latinLookalikes[r]; !ok {
+				allLookalike = false
+			}
+		}
+	}
+
+	var violations []Violation
+
+	if len(seen) > 1 && !allowedMix(seen) {
+		violations = append(violations, Violation{
+			Rule:    "mixed_script",
+			Message: "alias mixes writing systems in a way that can be used for spoofing",
+		})
+	}
+
+	if letters > 0 && allLookalike && (seen["Cyrillic"] || seen["Greek"]) {
+		violations = append(violations, Violation{
+			Rule:    "confusable",
+			Message: "alias can be confused with a Latin alias",
+		})
+	}
+
+	return violations
+}
+
+func allowedMix(seen map[string]bool) bool {
+	for _, set := range highlyRestrictive {
+		ok := true
+		for script := range seen {
+			if !slices.Contains(set, script) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptOf returns the Unicode script of r, or "" for characters shared
+// between scripts (digits, punctuation, emoji, combining marks).
+func scriptOf(r rune) string {
+	if unicode.Is(unicode.Common, r) || unicode.Is(unicode.Inherited, r) {
+		return ""
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
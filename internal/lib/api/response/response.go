@@ -1,14 +1,24 @@
 package response
 
 import (
+	"URL-Shortener/internal/lib/alias"
 	"fmt"
 	"github.com/go-playground/validator/v10"
+	"reflect"
 	"strings"
 )
 
 type Response struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Status  string       `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError is a machine-readable description of one invalid field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 const (
@@ -30,20 +40,59 @@ func Error(msg string) Response {
 }
 
 func ValidationError(errs validator.ValidationErrors) Response {
-	var errMsg []string
+	var details []FieldError
 
 	for _, err := range errs {
+		var msg string
 		switch err.ActualTag() {
-		case "required":
-			errMsg = append(errMsg, fmt.Sprintf("field %s is required", err.Field()))
+		case "required", "required_without", "required_without_all":
+			msg = fmt.Sprintf("field %s is required", err.Field())
+		case "excluded_with":
+			msg = fmt.Sprintf("field %s cannot be combined with %s", err.Field(), err.Param())
 		case "url":
-			errMsg = append(errMsg, fmt.Sprintf("field %s is not valid Url", err.Field()))
+			msg = fmt.Sprintf("field %s is not valid Url", err.Field())
+		case "max":
+			msg = fmt.Sprintf("field %s is too long", err.Field())
+			if err.Kind() == reflect.Int {
+				msg = fmt.Sprintf("field %s must be at most %s", err.Field(), err.Param())
+			}
+		case "unique":
+			msg = fmt.Sprintf("field %s must not contain duplicates", err.Field())
+		case "oneof":
+			msg = fmt.Sprintf("field %s must be one of: %s", err.Field(), err.Param())
 		default:
-			errMsg = append(errMsg, fmt.Sprintf("field %s is not valid", err.Field()))
+			msg = fmt.Sprintf("field %s is not valid", err.Field())
 		}
+		details = append(details, FieldError{
+			Field:   err.Field(),
+			Rule:    err.ActualTag(),
+			Message: msg,
+		})
+	}
+	return ValidationFailed(details)
+}
+
+// AliasPolicyError reports the alias policy rules broken by field.
+func AliasPolicyError(field string, violations []alias.Violation) Response {
+	details := make([]FieldError, 0, len(violations))
+	for _, v := range violations {
+		details = append(details, FieldError{
+			Field:   field,
+			Rule:    v.Rule,
+			Message: v.Message,
+		})
+	}
+	return ValidationFailed(details)
+}
+
+func ValidationFailed(details []FieldError) Response {
+	errMsg := make([]string, 0, len(details))
+	for _, d := range details {
+		errMsg = append(errMsg, d.Message)
 	}
 	return Response{
-		Status: StatusError,
-		Error:  strings.Join(errMsg, "; "),
+		Status:  StatusError,
+		Error:   strings.Join(errMsg, "; "),
+		Details: details,
 	}
 }
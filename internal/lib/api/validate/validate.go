@@ -0,0 +1,21 @@
+package validate
+
+import (
+	"github.com/go-playground/validator/v10"
+	"sync"
+)
+
+var (
+	validate *validator.Validate
+	initOnce sync.Once
+)
+
+// Struct validates the fields of a request struct by their validate tags.
+// Failures are validator.ValidationErrors. The validator caches struct
+// metadata, so all handlers share one.
+func Struct(s any) error {
+	initOnce.Do(func() {
+		validate = validator.New()
+	})
+	return validate.Struct(s)
+}
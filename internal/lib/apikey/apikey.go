@@ -0,0 +1,27 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const prefix = "us_"
+
+// Generate returns a new random API key together with the hash under
+// which it should be stored. The plain key is never persisted.
+func Generate() (key string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("apikey.Generate: %w", err)
+	}
+
+	key = prefix + hex.EncodeToString(b)
+	return key, Hash(key), nil
+}
+
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+package applink
+
+import (
+	"URL-Shortener/internal/lib/destination"
+	"URL-Shortener/internal/lib/useragent"
+	"URL-Shortener/internal/storage"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrNoAppURL        = errors.New("app fallbacks need an app url")
+	ErrInvalidAppURL   = errors.New("app url must be a custom scheme or universal link, e.g. myapp://product/42")
+	ErrInvalidFallback = errors.New("invalid app fallback URL format")
+)
+
+// Schemes that run code or read local files in the browser instead of
+// opening an app.
+var unsafeSchemes = map[string]bool{
+	"javascript": true,
+	"data":       true,
+	"vbscript":   true,
+	"file":       true,
+	"blob":       true,
+}
+
+// Normalize checks an app link given through the API and returns it with
+// its fallbacks normalized like destination URLs.
+func Normalize(link storage.AppLink) (storage.AppLink, error) {
+	if link.URL == "" {
+		if link.IOSFallback != "" || link.AndroidFallback != "" {
+			return storage.AppLink{}, ErrNoAppURL
+		}
+		return link, nil
+	}
+
+	u, err := url.Parse(link.URL)
+	if err != nil || u.Scheme == "" || unsafeSchemes[strings.ToLower(u.Scheme)] {
+		return storage.AppLink{}, ErrInvalidAppURL
+	}
+
+	for _, fallback := range []*string{&link.IOSFallback, &link.AndroidFallback} {
+		if *fallback == "" {
+			continue
+		}
+		var ok bool
+		if *fallback, ok = destination.Normalize(*fallback); !ok {
+			return storage.AppLink{}, ErrInvalidFallback
+		}
+	}
+
+	return link, nil
+}
+
+// Fallback returns where a client on os goes if the app does not open,
+// and whether os is a platform the app link applies to at all.
+func Fallback(link storage.AppLink, os string, dest string) (string, bool) {
+	if link.URL == "" {
+		return "", false
+	}
+
+	switch os {
+	case useragent.OSIOS:
+		if link.IOSFallback != "" {
+			return link.IOSFallback, true
+		}
+	case useragent.OSAndroid:
+		if link.AndroidFallback != "" {
+			return link.AndroidFallback, true
+		}
+	default:
+		return "", false
+	}
+	return dest, true
+}
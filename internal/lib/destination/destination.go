@@ -0,0 +1,21 @@
+package destination
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Normalize turns a destination given through the API into an absolute
+// URL, assuming https when it has no http or https scheme, and reports
+// whether the result is valid, i.e. has a host.
+func Normalize(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, "http://") && !strings.HasPrefix(raw, "https://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw, false
+	}
+	return raw, true
+}
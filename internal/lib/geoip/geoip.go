@@ -0,0 +1,165 @@
+package geoip
+
+import (
+	"URL-Shortener/internal/lib/logger/sl"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Location is what a GeoIP2 or GeoLite2 City/Country database knows
+// about an address. Fields are empty when unknown.
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code, e.g. "DE".
+	Country string
+	// Continent is the two-letter continent code, e.g. "EU" for Europe.
+	Continent string
+	// Region is the ISO 3166-2 code of the first subdivision, e.g.
+	// "US-CA". Country databases do not have it.
+	Region string
+	// EU reports membership of the European Union.
+	EU bool
+}
+
+// record holds the fields of a City or Country database entry that
+// Location is built from.
+type record struct {
+	Country struct {
+		ISOCode           string `maxminddb:"iso_code"`
+		IsInEuropeanUnion bool   `maxminddb:"is_in_european_union"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+}
+
+// DB is a MaxMind database file that is reloaded when it changes on
+// disk, so that it can be updated (e.g. by geoipupdate) without a
+// restart. It never goes to the network. A nil DB locates nothing.
+type DB struct {
+	log  *slog.Logger
+	path string
+
+	current atomic.Pointer[maxminddb.Reader]
+	modTime time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Open loads the database at path and, if reloadInterval is positive,
+// checks it for changes at that interval.
+func Open(log *slog.Logger, path string, reloadInterval time.Duration) (*DB, error) {
+	const op = "geoip.Open"
+
+	db := &DB{
+		log:  log.With(slog.String("component", "geoip")),
+		path: path,
+		stop: make(chan struct{}),
+	}
+	if _, err := db.reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if reloadInterval > 0 {
+		go db.watch(reloadInterval)
+	}
+
+	return db, nil
+}
+
+// Lookup returns the location of ip; unknown addresses give a zero
+// Location.
+func (db *DB) Lookup(ip net.IP) (Location, error) {
+	const op = "geoip.Lookup"
+
+	if db == nil || ip == nil {
+		return Location{}, nil
+	}
+
+	var rec record
+	if err := db.current.Load().Lookup(ip, &rec); err != nil {
+		return Location{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	loc := Location{
+		Country:   rec.Country.ISOCode,
+		Continent: rec.Continent.Code,
+		EU:        rec.Country.IsInEuropeanUnion,
+	}
+	if loc.Country == "" {
+		// Anonymous proxies and satellite providers only have the
+		// country the address is registered in.
+		loc.Country = rec.RegisteredCountry.ISOCode
+	}
+	if len(rec.Subdivisions) > 0 && rec.Subdivisions[0].ISOCode != "" && loc.Country != "" {
+		loc.Region = loc.Country + "-" + rec.Subdivisions[0].ISOCode
+	}
+
+	return loc, nil
+}
+
+// Close stops watching the file for changes.
+func (db *DB) Close() {
+	if db == nil {
+		return
+	}
+	db.stopOnce.Do(func() { close(db.stop) })
+}
+
+func (db *DB) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-ticker.C:
+			reloaded, err := db.reload()
+			if err != nil {
+				// Keep serving the previous version.
+				db.log.Error("failed to reload database", slog.String("path", db.path), sl.Err(err))
+				continue
+			}
+			if reloaded {
+				db.log.Info("database reloaded", slog.String("path", db.path))
+			}
+		}
+	}
+}
+
+// reload reads the file again if its modification time changed.
+func (db *DB) reload() (bool, error) {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(db.modTime) {
+		return false, nil
+	}
+
+	buf, err := os.ReadFile(db.path)
+	if err != nil {
+		return false, err
+	}
+	reader, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return false, err
+	}
+
+	db.current.Store(reader)
+	db.modTime = info.ModTime()
+	return true, nil
+}
@@ -0,0 +1,92 @@
+package linkpass
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxLength is the longest password bcrypt accepts, in bytes.
+const MaxLength = 72
+
+var ErrTooLong = errors.New("password must be at most 72 bytes")
+
+// Hash returns the bcrypt hash a link password is stored under. The
+// plain password is never persisted.
+func Hash(password string) (string, error) {
+	if len(password) > MaxLength {
+		return "", ErrTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("linkpass.Hash: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Check reports whether password matches hash.
+func Check(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// Signer issues and verifies the tokens that remember a visitor already
+// gave a link's password. A token is bound to the link and to its
+// password hash, so changing the password revokes it.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewSigner(secret []byte, ttl time.Duration) *Signer {
+	return &Signer{secret: secret, ttl: ttl}
+}
+
+// TTL is how long a token stays valid.
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Sign returns a token for link, identified by any stable key, valid
+// until now plus the TTL.
+func (s *Signer) Sign(link string, hash string, now time.Time) string {
+	expires := strconv.FormatInt(now.Add(s.ttl).Unix(), 10)
+	return expires + "." + base64.RawURLEncoding.EncodeToString(s.mac(link, hash, expires))
+}
+
+// Verify reports whether token was issued by Sign for link and hash and
+// has not expired.
+func (s *Signer) Verify(token string, link string, hash string, now time.Time) bool {
+	expires, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() >= unix {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, s.mac(link, hash, expires))
+}
+
+// CookieName returns a cookie name specific to link, so that unlocking
+// one link does not overwrite the token of another.
+func CookieName(link string) string {
+	sum := sha256.Sum256([]byte(link))
+	return "us_pw_" + hex.EncodeToString(sum[:8])
+}
+
+func (s *Signer) mac(link string, hash string, expires string) []byte {
+	m := hmac.New(sha256.New, s.secret)
+	m.Write([]byte(link + "\x00" + hash + "\x00" + expires))
+	return m.Sum(nil)
+}
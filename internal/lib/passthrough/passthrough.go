@@ -0,0 +1,100 @@
+package passthrough
+
+import (
+	"URL-Shortener/internal/storage"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// QueryOff drops the request's query string.
+	QueryOff = "off"
+	// QueryLink merges the request's query into the destination's,
+	// keeping the destination's value when a parameter is in both.
+	QueryLink = "link"
+	// QueryRequest merges like QueryLink but lets the request win.
+	QueryRequest = "request"
+	// QueryAppend keeps the values of both for repeated parameters.
+	QueryAppend = "append"
+)
+
+const (
+	// FragmentLink keeps the destination's fragment.
+	FragmentLink = "link"
+	// FragmentRequest drops the destination's fragment so that browsers
+	// carry the fragment of the short link over to the destination.
+	FragmentRequest = "request"
+)
+
+var ErrUnsafePath = errors.New("path would escape the destination")
+
+// Apply returns dest with the trailing path segments rest and the request
+// query carried over as p asks. rest holds escaped path segments; dot
+// segments are rejected so that the result can never leave the
+// destination's host or the path below it.
+func Apply(dest string, p storage.Passthrough, rest []string, query url.Values) (string, error) {
+	const op = "passthrough.Apply"
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if p.Path && len(rest) > 0 {
+		escaped := make([]string, 0, len(rest))
+		for _, s := range rest {
+			segment, err := url.PathUnescape(s)
+			if err != nil {
+				return "", fmt.Errorf("%s: %w", op, err)
+			}
+			if segment == "" {
+				continue
+			}
+			if segment == "." || segment == ".." || strings.ContainsAny(segment, "/\\") {
+				return "", fmt.Errorf("%s: %w", op, ErrUnsafePath)
+			}
+			escaped = append(escaped, url.PathEscape(segment))
+		}
+
+		if len(escaped) > 0 {
+			raw := strings.TrimRight(u.EscapedPath(), "/") + "/" + strings.Join(escaped, "/")
+			if u.Path, err = url.PathUnescape(raw); err != nil {
+				return "", fmt.Errorf("%s: %w", op, err)
+			}
+			u.RawPath = raw
+		}
+	}
+
+	if len(query) > 0 && p.Query != "" && p.Query != QueryOff {
+		u.RawQuery = mergeQuery(u.Query(), query, p.Query).Encode()
+	}
+
+	if p.Fragment == FragmentRequest {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	return u.String(), nil
+}
+
+func mergeQuery(dest url.Values, req url.Values, mode string) url.Values {
+	switch mode {
+	case QueryLink:
+		for k, v := range req {
+			if _, ok := dest[k]; !ok {
+				dest[k] = v
+			}
+		}
+	case QueryRequest:
+		for k, v := range req {
+			dest[k] = v
+		}
+	case QueryAppend:
+		for k, v := range req {
+			dest[k] = append(dest[k], v...)
+		}
+	}
+	return dest
+}
@@ -0,0 +1,199 @@
+package random
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	ModeLetters      = "letters"
+	ModeAlphanumeric = "alphanumeric"
+	// ModeCrockford draws from Crockford's base32 alphabet, which leaves
+	// out i, l, o and u so aliases survive being read aloud or printed.
+	ModeCrockford = "crockford"
+	// ModeWords produces aliases such as "brave-otter-42".
+	ModeWords = "words"
+)
+
+const (
+	alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	crockford    = "0123456789abcdefghjkmnpqrstvwxyz"
+	// wordsCheckAlphabet is used to compute the check character of word
+	// aliases; separators are skipped.
+	wordsCheckAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// checkSeparator precedes the check character of aliases drawn from an
+// alphabet, so that custom aliases one character longer than generated
+// ones are not mistaken for them. Word aliases are recognised by their
+// words instead.
+const checkSeparator = '-'
+
+var wordsShape = regexp.MustCompile(`^([a-z]+)-([a-z]+)-([0-9]{2})(.?)$`)
+
+// Generator produces random aliases and, when check characters are
+// enabled, validates them without a storage round trip.
+type Generator struct {
+	mode      string
+	alphabet  []rune
+	index     map[rune]int
+	checkChar bool
+}
+
+func NewGenerator(mode string, checkChar bool) (*Generator, error) {
+	var alphabet string
+	switch mode {
+	case "", ModeLetters:
+		mode, alphabet = ModeLetters, string(letters)
+	case ModeAlphanumeric:
+		alphabet = alphanumeric
+	case ModeCrockford:
+		alphabet = crockford
+	case ModeWords:
+		alphabet = wordsCheckAlphabet
+	default:
+		return nil, fmt.Errorf("random.NewGenerator: unknown mode %q", mode)
+	}
+
+	g := &Generator{
+		mode:      mode,
+		alphabet:  []rune(alphabet),
+		index:     make(map[rune]int, len(alphabet)),
+		checkChar: checkChar,
+	}
+	for i, r := range g.alphabet {
+		g.index[r] = i
+	}
+
+	return g, nil
+}
+
+func (g *Generator) HasCheckChar() bool {
+	return g.checkChar
+}
+
+// CaseSensitive reports whether the alphabet tells upper and lower case
+// apart. Check characters of such aliases do not survive case folding.
+func (g *Generator) CaseSensitive() bool {
+	return g.mode == ModeLetters || g.mode == ModeAlphanumeric
+}
+
+// Generate returns a new alias of length symbols, plus the check
+// character if enabled. Length is ignored in words mode.
+func (g *Generator) Generate(length int) string {
+	var alias string
+	if g.mode == ModeWords {
+		alias = fmt.Sprintf("%s-%s-%02d",
+			adjectives[rand.IntN(len(adjectives))],
+			nouns[rand.IntN(len(nouns))],
+			10+rand.IntN(90),
+		)
+	} else {
+		b := make([]rune, length)
+		for i := range b {
+			b[i] = g.alphabet[rand.IntN(len(g.alphabet))]
+		}
+		alias = string(b)
+	}
+
+	if g.checkChar {
+		if g.mode != ModeWords {
+			alias += string(checkSeparator)
+		}
+		alias += string(g.checkSymbol(alias))
+	}
+	return alias
+}
+
+// Canonical maps alias onto the generator's alphabet. Word aliases are
+// case-insensitive; Crockford ones are too, and additionally read the
+// confusable i, l and o as 1, 1 and 0. Other modes keep alias as is.
+func (g *Generator) Canonical(alias string) string {
+	switch g.mode {
+	case ModeWords:
+		return strings.ToLower(alias)
+	case ModeCrockford:
+		return strings.NewReplacer("i", "1", "l", "1", "o", "0").Replace(strings.ToLower(alias))
+	default:
+		return alias
+	}
+}
+
+// Shaped reports whether alias looks like one this generator produces
+// for the given length, i.e. whether it is expected to carry a valid
+// check character. It is always false with check characters disabled.
+func (g *Generator) Shaped(alias string, length int) bool {
+	if !g.checkChar {
+		return false
+	}
+
+	if g.mode == ModeWords {
+		m := wordsShape.FindStringSubmatch(alias)
+		return m != nil && m[4] != "" && slices.Contains(adjectives, m[1]) && slices.Contains(nouns, m[2])
+	}
+
+	runes := []rune(alias)
+	if len(runes) != length+2 || runes[length] != checkSeparator {
+		return false
+	}
+	for i, r := range runes {
+		if _, ok := g.index[r]; !ok && i != length {
+			return false
+		}
+	}
+	return true
+}
+
+// Admit checks a custom alias against the generator's check characters.
+// One that looks generated must carry a valid check character, or
+// redirects would reject it as a typo; it is then returned in canonical
+// form, which is how redirects look it up. Case-insensitive scopes skip
+// the check when the alphabet is case-sensitive since folding would
+// invalidate it.
+func (g *Generator) Admit(alias string, length int, caseInsensitive bool) (string, bool) {
+	if caseInsensitive && g.CaseSensitive() {
+		return alias, true
+	}
+	canonical := g.Canonical(alias)
+	if !g.Shaped(canonical, length) {
+		return alias, true
+	}
+	return canonical, g.Verify(canonical)
+}
+
+// Verify reports whether the last character of alias is the correct
+// check character for the rest of it. Separators are not checked.
+func (g *Generator) Verify(alias string) bool {
+	runes := []rune(alias)
+	if len(runes) < 2 {
+		return false
+	}
+	body, check := string(runes[:len(runes)-1]), runes[len(runes)-1]
+	return g.checkSymbol(body) == check
+}
+
+// checkSymbol computes a Luhn mod N check character over the symbols of
+// s that belong to the alphabet. It catches every single-character
+// substitution and most transpositions of adjacent characters.
+func (g *Generator) checkSymbol(s string) rune {
+	n := len(g.alphabet)
+	factor := 2
+	sum := 0
+
+	runes := []rune(s)
+	for i := len(runes) - 1; i >= 0; i-- {
+		code, ok := g.index[runes[i]]
+		if !ok {
+			continue
+		}
+		addend := factor * code
+		addend = addend/n + addend%n
+		sum += addend
+		factor = 3 - factor
+	}
+
+	return g.alphabet[(n-sum%n)%n]
+}
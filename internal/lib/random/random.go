@@ -1,19 +1,3 @@
 package random
 
-import (
-	"math/rand"
-	"time"
-)
-
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-
-func NewRandomAlias(size int) string {
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	b := make([]rune, size)
-	for i := range b {
-		b[i] = letters[rnd.Intn(len(letters))]
-	}
-
-	return string(b)
-}
@@ -0,0 +1,25 @@
+package random
+
+// Word lists for ModeWords. Entries are short, easy to spell and to say
+// over the phone, and deliberately free of near-homophones.
+var adjectives = []string{
+	"amber", "bold", "brave", "brisk", "calm", "clever", "cosy", "crisp",
+	"daring", "eager", "early", "fair", "fancy", "fast", "fluffy", "fond",
+	"gentle", "giant", "glad", "golden", "grand", "happy", "hardy", "honest",
+	"humble", "jolly", "keen", "kind", "lively", "lucky", "mellow", "merry",
+	"mighty", "misty", "modest", "noble", "odd", "patient", "plucky", "polite",
+	"proud", "quick", "quiet", "rapid", "ready", "rosy", "royal", "rustic",
+	"sandy", "shiny", "silent", "silver", "sleek", "smart", "snowy", "sturdy",
+	"sunny", "swift", "tidy", "tiny", "vivid", "warm", "wild", "witty",
+}
+
+var nouns = []string{
+	"badger", "beaver", "bison", "camel", "cobra", "condor", "coral", "crane",
+	"dingo", "dolphin", "eagle", "falcon", "ferret", "finch", "gecko", "heron",
+	"hippo", "husky", "ibis", "iguana", "jackal", "koala", "lemur", "llama",
+	"lynx", "magpie", "marlin", "marmot", "moose", "narwhal", "newt", "ocelot",
+	"orca", "osprey", "otter", "panda", "parrot", "pelican", "penguin", "puffin",
+	"python", "quail", "rabbit", "raven", "robin", "salmon", "seal", "shark",
+	"sloth", "spider", "squid", "stork", "swan", "tapir", "tiger", "toucan",
+	"trout", "turtle", "viper", "walrus", "weasel", "whale", "wombat", "zebra",
+}
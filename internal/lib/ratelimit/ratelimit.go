@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter counts attempts per key, e.g. password guesses per link and
+// client, and blocks a key once it has made max attempts within window
+// without a success.
+// It lives in memory, so every instance of the service counts on its
+// own.
+type Limiter struct {
+	max    int
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	attempts int
+	// reset is when the window of the first attempt ends.
+	reset time.Time
+}
+
+// sweepAt is the number of keys above which expired ones are dropped.
+const sweepAt = 10000
+
+func New(max int, window time.Duration) *Limiter {
+	return &Limiter{
+		max:     max,
+		window:  window,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Take reserves an attempt for key and reports whether it may be made,
+// and if not, how long until it may. Attempts count as failures until
+// Reset; taking them up front keeps concurrent attempts from all slipping
+// through before any has failed.
+func (l *Limiter) Take(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok || !now.Before(e.reset) {
+		if len(l.entries) >= sweepAt {
+			l.sweep(now)
+		}
+		e = &entry{reset: now.Add(l.window)}
+		l.entries[key] = e
+	}
+	if e.attempts >= l.max {
+		return false, e.reset.Sub(now)
+	}
+	e.attempts++
+	return true, 0
+}
+
+// Reset forgets the attempts of key, e.g. after a success.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, e := range l.entries {
+		if !now.Before(e.reset) {
+			delete(l.entries, key)
+		}
+	}
+}
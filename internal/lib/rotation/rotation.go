@@ -0,0 +1,36 @@
+package rotation
+
+import (
+	"hash/fnv"
+)
+
+// Pick returns the index of the weight whose share of the total key
+// hashes into. The same key always picks the same index for unchanged
+// weights, which keeps visitors on one variant. Non-positive weights are
+// never picked; -1 is returned when no weight is positive.
+func Pick(weights []int, key string) int {
+	total := 0
+	for _, w := range weights {
+		if w > 0 {
+			total += w
+		}
+	}
+	if total == 0 {
+		return -1
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	n := int(h.Sum64() % uint64(total))
+
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return -1
+}
@@ -0,0 +1,53 @@
+package shorturl
+
+import (
+	"URL-Shortener/internal/http-server/middleware/forwarded"
+	"URL-Shortener/internal/storage"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Builder turns aliases into fully qualified short URLs.
+type Builder struct {
+	baseURL string
+}
+
+// New returns a Builder for the deployment-wide public base URL, which
+// may include a path prefix when the service sits behind a reverse proxy.
+// An empty baseURL makes the builder fall back to the request's host.
+func New(baseURL string) *Builder {
+	return &Builder{baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Build returns the short URL for alias on domain. A domain's own base URL
+// wins over the deployment one; a registered domain without one is
+// assumed to be served over https at its root.
+func (b *Builder) Build(r *http.Request, domain storage.Domain, alias string) string {
+	return Join(b.base(r, domain), alias)
+}
+
+func (b *Builder) base(r *http.Request, domain storage.Domain) string {
+	switch {
+	case domain.BaseURL != "":
+		return strings.TrimRight(domain.BaseURL, "/")
+	case domain.Host != "":
+		return "https://" + domain.Host
+	case b.baseURL != "":
+		return b.baseURL
+	default:
+		// Behind a trusted proxy, the forwarded middleware has set these
+		// to what the client asked for.
+		return forwarded.Scheme(r) + "://" + r.Host
+	}
+}
+
+// Join appends alias to base, escaping each of its '/'-separated
+// segments.
+func Join(base string, alias string) string {
+	segments := strings.Split(alias, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,141 @@
+package targeting
+
+import (
+	"URL-Shortener/internal/lib/geoip"
+	"URL-Shortener/internal/lib/useragent"
+	"URL-Shortener/internal/storage"
+	"golang.org/x/text/language"
+	"regexp"
+	"slices"
+)
+
+// EU stands for every member state of the European Union in a rule's
+// countries.
+const EU = "EU"
+
+var (
+	countryRe = regexp.MustCompile(`^[A-Z]{2}$`)
+	regionRe  = regexp.MustCompile(`^[A-Z]{2}-[A-Z0-9]{1,3}$`)
+)
+
+// Client is what rules are matched against.
+type Client struct {
+	UserAgent      string
+	AcceptLanguage string
+	// Locate returns the client's location. It is only called if a rule
+	// has location conditions, and at most once.
+	Locate func() geoip.Location
+}
+
+// Match returns the index of the first rule that matches client, or -1.
+func Match(rules []storage.TargetRule, client Client) int {
+	if len(rules) == 0 {
+		return -1
+	}
+
+	agent := useragent.Parse(client.UserAgent)
+	preferred := preferredLanguage(client.AcceptLanguage)
+
+	var location *geoip.Location
+	locate := func() geoip.Location {
+		if location == nil {
+			location = &geoip.Location{}
+			if client.Locate != nil {
+				*location = client.Locate()
+			}
+		}
+		return *location
+	}
+
+	for i, rule := range rules {
+		if matches(rule, agent, preferred) && matchesLocation(rule, locate) {
+			return i
+		}
+	}
+	return -1
+}
+
+func matchesLocation(rule storage.TargetRule, locate func() geoip.Location) bool {
+	if len(rule.Countries) > 0 {
+		loc := locate()
+		if !slices.Contains(rule.Countries, loc.Country) && !(loc.EU && slices.Contains(rule.Countries, EU)) {
+			return false
+		}
+	}
+	if len(rule.Regions) > 0 && !slices.Contains(rule.Regions, locate().Region) {
+		return false
+	}
+	return true
+}
+
+func matches(rule storage.TargetRule, agent useragent.Agent, preferred *language.Tag) bool {
+	if len(rule.OS) > 0 && !slices.Contains(rule.OS, agent.OS) {
+		return false
+	}
+	if len(rule.Devices) > 0 && !slices.Contains(rule.Devices, agent.Device) {
+		return false
+	}
+	if len(rule.Browsers) > 0 && !slices.Contains(rule.Browsers, agent.Browser) {
+		return false
+	}
+	if len(rule.Languages) > 0 {
+		if preferred == nil {
+			return false
+		}
+		return slices.ContainsFunc(rule.Languages, func(l string) bool {
+			return languageMatches(l, *preferred)
+		})
+	}
+	return true
+}
+
+// preferredLanguage returns the client's most preferred language, if any.
+// Only the top choice is used so that a rule for a fallback language
+// does not win over the language the visitor actually reads.
+func preferredLanguage(header string) *language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 {
+		return nil
+	}
+	return &tags[0]
+}
+
+// languageMatches reports whether tag is covered by the rule language
+// want: "en" covers every English variant, "en-GB" only British English.
+func languageMatches(want string, tag language.Tag) bool {
+	w, err := language.Parse(want)
+	if err != nil {
+		return false
+	}
+
+	wb, _ := w.Base()
+	tb, _ := tag.Base()
+	if wb != tb {
+		return false
+	}
+
+	wr, conf := w.Region()
+	if conf != language.Exact {
+		return true
+	}
+	tr, _ := tag.Region()
+	return wr == tr
+}
+
+// ValidCountry reports whether c is an upper-case ISO 3166-1 alpha-2
+// code or EU.
+func ValidCountry(c string) bool {
+	return countryRe.MatchString(c)
+}
+
+// ValidRegion reports whether r looks like an upper-case ISO 3166-2
+// subdivision code, e.g. "US-CA".
+func ValidRegion(r string) bool {
+	return regionRe.MatchString(r)
+}
+
+// ValidLanguage reports whether l is a well-formed BCP 47 tag.
+func ValidLanguage(l string) bool {
+	_, err := language.Parse(l)
+	return err == nil
+}
@@ -0,0 +1,102 @@
+package useragent
+
+import (
+	"strings"
+)
+
+const (
+	OSIOS      = "ios"
+	OSAndroid  = "android"
+	OSWindows  = "windows"
+	OSMacOS    = "macos"
+	OSLinux    = "linux"
+	OSChromeOS = "chromeos"
+	OSOther    = "other"
+)
+
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+const (
+	BrowserChrome  = "chrome"
+	BrowserSafari  = "safari"
+	BrowserFirefox = "firefox"
+	BrowserEdge    = "edge"
+	BrowserOpera   = "opera"
+	BrowserSamsung = "samsung"
+	BrowserOther   = "other"
+)
+
+var (
+	OSes     = []string{OSIOS, OSAndroid, OSWindows, OSMacOS, OSLinux, OSChromeOS, OSOther}
+	Devices  = []string{DeviceMobile, DeviceTablet, DeviceDesktop, DeviceBot}
+	Browsers = []string{BrowserChrome, BrowserSafari, BrowserFirefox, BrowserEdge, BrowserOpera, BrowserSamsung, BrowserOther}
+)
+
+// Agent is the coarse classification of a User-Agent header that
+// targeting rules match on.
+type Agent struct {
+	OS      string
+	Device  string
+	Browser string
+}
+
+var bots = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "preview", "curl/", "wget/"}
+
+// Parse classifies ua. It only looks for well-known tokens, so anything
+// it does not recognise ends up as "other" or "desktop".
+func Parse(ua string) Agent {
+	s := strings.ToLower(ua)
+
+	a := Agent{OS: OSOther, Device: DeviceDesktop, Browser: BrowserOther}
+
+	switch {
+	case strings.Contains(s, "iphone"), strings.Contains(s, "ipod"):
+		a.OS, a.Device = OSIOS, DeviceMobile
+	case strings.Contains(s, "ipad"):
+		a.OS, a.Device = OSIOS, DeviceTablet
+	case strings.Contains(s, "android"):
+		// Android tablets leave "Mobile" out of their user agent.
+		a.OS, a.Device = OSAndroid, DeviceTablet
+		if strings.Contains(s, "mobile") {
+			a.Device = DeviceMobile
+		}
+	case strings.Contains(s, "windows"):
+		a.OS = OSWindows
+	case strings.Contains(s, "cros"):
+		a.OS = OSChromeOS
+	case strings.Contains(s, "macintosh"), strings.Contains(s, "mac os x"):
+		a.OS = OSMacOS
+	case strings.Contains(s, "linux"):
+		a.OS = OSLinux
+	}
+
+	// Order matters: most browsers also claim to be Chrome and Safari.
+	switch {
+	case strings.Contains(s, "edg/"), strings.Contains(s, "edga/"), strings.Contains(s, "edgios/"):
+		a.Browser = BrowserEdge
+	case strings.Contains(s, "opr/"), strings.Contains(s, "opera"):
+		a.Browser = BrowserOpera
+	case strings.Contains(s, "samsungbrowser/"):
+		a.Browser = BrowserSamsung
+	case strings.Contains(s, "firefox/"), strings.Contains(s, "fxios/"):
+		a.Browser = BrowserFirefox
+	case strings.Contains(s, "chrome/"), strings.Contains(s, "crios/"), strings.Contains(s, "chromium/"):
+		a.Browser = BrowserChrome
+	case strings.Contains(s, "safari/"):
+		a.Browser = BrowserSafari
+	}
+
+	for _, b := range bots {
+		if strings.Contains(s, b) {
+			a.Device = DeviceBot
+			break
+		}
+	}
+
+	return a
+}
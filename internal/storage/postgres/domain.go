@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"URL-Shortener/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+func (s *Storage) GetDomain(host string) (storage.Domain, error) {
+	const op = "storage.postgres.GetDomain"
+
+	ctx := context.Background()
+
+	var d storage.Domain
+	err := s.pool.QueryRow(ctx, `SELECT host, workspace_id, fallback_url, base_url FROM domains WHERE host = $1`, host).
+		Scan(&d.Host, &d.WorkspaceID, &d.FallbackURL, &d.BaseURL)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Domain{}, storage.ErrDomainNotFound
+		}
+		return storage.Domain{}, fmt.Errorf("%s: query: %w", op, err)
+	}
+
+	return d, nil
+}
+
+func (s *Storage) SaveDomain(d storage.Domain) error {
+	const op = "storage.postgres.SaveDomain"
+
+	ctx := context.Background()
+
+	// Re-registering a host may change its settings but never move it to
+	// another workspace, as that would silently hand over its links.
+	result, err := s.pool.Exec(ctx, `
+		INSERT INTO domains(host, workspace_id, fallback_url, base_url) VALUES($1, $2, $3, $4)
+		ON CONFLICT (host) DO UPDATE SET fallback_url = EXCLUDED.fallback_url, base_url = EXCLUDED.base_url
+		WHERE domains.workspace_id = EXCLUDED.workspace_id
+	`, d.Host, d.WorkspaceID, d.FallbackURL, d.BaseURL)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" { // foreign_key_violation
+			return fmt.Errorf("%s: %w", op, storage.ErrWorkspaceNotFound)
+		}
+		return fmt.Errorf("%s: exec: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDomainExists)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListDomains() ([]storage.Domain, error) {
+	const op = "storage.postgres.ListDomains"
+
+	ctx := context.Background()
+
+	rows, err := s.pool.Query(ctx, `SELECT host, workspace_id, fallback_url, base_url FROM domains ORDER BY host`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var domains []storage.Domain
+	for rows.Next() {
+		var d storage.Domain
+		if err := rows.Scan(&d.Host, &d.WorkspaceID, &d.FallbackURL, &d.BaseURL); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return domains, nil
+}
+
+func (s *Storage) DeleteDomain(host string) error {
+	const op = "storage.postgres.DeleteDomain"
+
+	ctx := context.Background()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM domains WHERE host = $1`, host)
+	if err != nil {
+		return fmt.Errorf("%s: exec: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return storage.ErrDomainNotFound
+	}
+
+	return nil
+}
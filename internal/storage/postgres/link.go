@@ -0,0 +1,417 @@
+package postgres
+
+import (
+	"URL-Shortener/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"strings"
+	"time"
+)
+
+const linkColumns = `domain, alias, url, title, notes, tags,
+	passthrough_path, passthrough_query, passthrough_fragment, state, active_from, active_until,
+	app_url, app_fallback_ios, app_fallback_android, password_hash,
+	COALESCE(max_clicks, 0), COALESCE(clicks_left, 0), interstitial, interstitial_message, created_at,
+	ARRAY(SELECT s.effective_from FROM link_schedule s WHERE s.url_id = urls.id ORDER BY s.effective_from),
+	ARRAY(SELECT s.url FROM link_schedule s WHERE s.url_id = urls.id ORDER BY s.effective_from),
+	(SELECT coalesce(json_agg(json_build_object(
+		'id', v.id, 'name', v.name, 'url', v.url, 'weight', v.weight, 'clicks', v.clicks) ORDER BY v.id), '[]')
+	FROM link_variants v WHERE v.url_id = urls.id),
+	(SELECT coalesce(json_agg(json_build_object(
+		'os', t.os, 'devices', t.devices, 'browsers', t.browsers, 'languages', t.languages,
+		'countries', t.countries, 'regions', t.regions, 'url', t.url)
+		ORDER BY t.position), '[]')
+	FROM link_targets t WHERE t.url_id = urls.id)`
+
+type variantRow struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	Clicks int64  `json:"clicks"`
+}
+
+type targetRow struct {
+	OS        []string `json:"os"`
+	Devices   []string `json:"devices"`
+	Browsers  []string `json:"browsers"`
+	Languages []string `json:"languages"`
+	Countries []string `json:"countries"`
+	Regions   []string `json:"regions"`
+	URL       string   `json:"url"`
+}
+
+func scanLink(row pgx.Row) (storage.Link, error) {
+	var link storage.Link
+	var switches []time.Time
+	var urls []string
+	var variants, targets []byte
+	err := row.Scan(&link.Domain, &link.Alias, &link.URL, &link.Title, &link.Notes, &link.Tags,
+		&link.Passthrough.Path, &link.Passthrough.Query, &link.Passthrough.Fragment, &link.State,
+		&link.ActiveFrom, &link.ActiveUntil, &link.App.URL, &link.App.IOSFallback, &link.App.AndroidFallback, &link.PasswordHash,
+		&link.MaxClicks, &link.ClicksLeft, &link.Interstitial.Enabled, &link.Interstitial.Message, &link.CreatedAt, &switches, &urls, &variants, &targets)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.Link{}, storage.ErrUrlNotFound
+	}
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	var rows []variantRow
+	if err := json.Unmarshal(variants, &rows); err != nil {
+		return storage.Link{}, fmt.Errorf("variants: %w", err)
+	}
+	for _, v := range rows {
+		link.Variants = append(link.Variants, storage.Variant(v))
+	}
+
+	var rules []targetRow
+	if err := json.Unmarshal(targets, &rules); err != nil {
+		return storage.Link{}, fmt.Errorf("targets: %w", err)
+	}
+	for _, t := range rules {
+		link.Targets = append(link.Targets, storage.TargetRule(t))
+	}
+
+	link.ActiveFrom, link.ActiveUntil = inUTC(link.ActiveFrom), inUTC(link.ActiveUntil)
+	link.CreatedAt = inUTC(link.CreatedAt)
+	for i := range switches {
+		link.Schedule = append(link.Schedule, storage.ScheduleEntry{EffectiveFrom: switches[i].UTC(), URL: urls[i]})
+	}
+	return link, nil
+}
+
+// inUTC converts t, which pgx scans in the local time zone, to UTC.
+func inUTC(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func (s *Storage) GetLink(scope storage.Scope, alias string) (storage.Link, error) {
+	const op = "storage.postgres.GetLink"
+
+	ctx := context.Background()
+
+	row := s.pool.QueryRow(ctx, `SELECT `+linkColumns+` FROM urls WHERE workspace_id = $1 AND domain = $2 AND alias = $3`,
+		scope.WorkspaceID, scope.Domain, scope.Key(alias))
+	link, err := scanLink(row)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// MatchLink returns the link stored under alias or, failing that, the
+// link with path passthrough whose alias is the longest segment-wise
+// prefix of alias, e.g. "gh" for "gh/repo/issues".
+func (s *Storage) MatchLink(scope storage.Scope, alias string) (storage.Link, error) {
+	const op = "storage.postgres.MatchLink"
+
+	ctx := context.Background()
+
+	alias = scope.Key(alias)
+	segments := strings.Split(alias, "/")
+	prefixes := make([]string, 0, len(segments)-1)
+	for i := 1; i < len(segments); i++ {
+		prefixes = append(prefixes, strings.Join(segments[:i], "/"))
+	}
+
+	row := s.pool.QueryRow(ctx, `
+		SELECT `+linkColumns+`
+		FROM urls
+		WHERE workspace_id = $1 AND domain = $2
+			AND (alias = $3 OR (passthrough_path AND alias = ANY($4)))
+		ORDER BY length(alias) DESC
+		LIMIT 1
+	`, scope.WorkspaceID, scope.Domain, alias, prefixes)
+	link, err := scanLink(row)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// UpdateLink applies patch to the link stored under alias and returns
+// the result.
+func (s *Storage) UpdateLink(scope storage.Scope, alias string, patch storage.LinkPatch) (storage.Link, error) {
+	const op = "storage.postgres.UpdateLink"
+
+	ctx := context.Background()
+
+	var pass storage.Passthrough
+	if patch.Passthrough != nil {
+		pass = *patch.Passthrough
+	}
+	var app storage.AppLink
+	if patch.App != nil {
+		app = *patch.App
+	}
+	var from, until storage.TimePatch
+	if patch.ActiveFrom != nil {
+		from = *patch.ActiveFrom
+	}
+	if patch.ActiveUntil != nil {
+		until = *patch.ActiveUntil
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, `
+		UPDATE urls SET
+			url = COALESCE($4, url),
+			title = COALESCE($5, title),
+			notes = COALESCE($6, notes),
+			tags = COALESCE($7::text[], tags),
+			passthrough_path = CASE WHEN $8 THEN $9 ELSE passthrough_path END,
+			passthrough_query = CASE WHEN $8 THEN $10 ELSE passthrough_query END,
+			passthrough_fragment = CASE WHEN $8 THEN $11 ELSE passthrough_fragment END,
+			state = CASE WHEN $4 IS NOT NULL THEN 'active' ELSE state END,
+			active_from = CASE WHEN $12 THEN $13::timestamptz ELSE active_from END,
+			active_until = CASE WHEN $14 THEN $15::timestamptz ELSE active_until END,
+			app_url = CASE WHEN $16 THEN $17 ELSE app_url END,
+			app_fallback_ios = CASE WHEN $16 THEN $18 ELSE app_fallback_ios END,
+			app_fallback_android = CASE WHEN $16 THEN $19 ELSE app_fallback_android END,
+			password_hash = COALESCE($20, password_hash),
+			max_clicks = CASE WHEN $21::int IS NULL THEN max_clicks ELSE NULLIF($21, 0) END,
+			clicks_left = CASE WHEN $21::int IS NULL THEN clicks_left ELSE NULLIF($21, 0) END
+		WHERE workspace_id = $1 AND domain = $2 AND alias = $3
+		RETURNING id`,
+		scope.WorkspaceID, scope.Domain, scope.Key(alias),
+		patch.URL, patch.Title, patch.Notes, patch.Tags,
+		patch.Passthrough != nil, pass.Path, pass.Query, pass.Fragment,
+		patch.ActiveFrom != nil, from.Time, patch.ActiveUntil != nil, until.Time,
+		patch.App != nil, app.URL, app.IOSFallback, app.AndroidFallback, patch.PasswordHash, patch.MaxClicks).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Link{}, storage.ErrUrlNotFound
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23514" { // check_violation
+			return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrInvalidWindow)
+		}
+		return storage.Link{}, fmt.Errorf("%s: update: %w", op, err)
+	}
+
+	if patch.Variants != nil {
+		if err := setVariants(ctx, tx, id, *patch.Variants); err != nil {
+			return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	link, err := scanLink(tx.QueryRow(ctx, `SELECT `+linkColumns+` FROM urls WHERE id = $1`, id))
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: select: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storage.Link{}, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// setVariants replaces the variants of link id, keeping the click counts
+// of those whose name stays.
+func setVariants(ctx context.Context, tx pgx.Tx, id int64, variants []storage.Variant) error {
+	names := make([]string, 0, len(variants))
+	for _, v := range variants {
+		names = append(names, v.Name)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM link_variants WHERE url_id = $1 AND NOT (name = ANY($2))`, id, names); err != nil {
+		return fmt.Errorf("delete variants: %w", err)
+	}
+
+	for _, v := range variants {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO link_variants(url_id, name, url, weight) VALUES($1, $2, $3, $4)
+			ON CONFLICT (url_id, name) DO UPDATE SET url = EXCLUDED.url, weight = EXCLUDED.weight
+		`, id, v.Name, v.URL, v.Weight)
+		if err != nil {
+			return fmt.Errorf("upsert variant: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// TakeClick uses up one of the clicks left on the link stored under
+// alias, which has a click limit, and reports whether there was one.
+// The check and the decrement are a single statement, so concurrent
+// redirects can never exceed the limit.
+func (s *Storage) TakeClick(scope storage.Scope, alias string) (bool, error) {
+	const op = "storage.postgres.TakeClick"
+
+	ctx := context.Background()
+
+	var left int
+	err := s.pool.QueryRow(ctx, `
+		UPDATE urls SET clicks_left = clicks_left - 1
+		WHERE workspace_id = $1 AND domain = $2 AND alias = $3
+			AND max_clicks IS NOT NULL AND clicks_left > 0
+		RETURNING clicks_left
+	`, scope.WorkspaceID, scope.Domain, scope.Key(alias)).Scan(&left)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return true, nil
+}
+
+// SetInterstitial turns the warning page of the link stored under alias
+// on or off.
+func (s *Storage) SetInterstitial(scope storage.Scope, alias string, interstitial storage.Interstitial) error {
+	const op = "storage.postgres.SetInterstitial"
+
+	ctx := context.Background()
+
+	result, err := s.pool.Exec(ctx, `
+		UPDATE urls SET interstitial = $4, interstitial_message = $5
+		WHERE workspace_id = $1 AND domain = $2 AND alias = $3
+	`, scope.WorkspaceID, scope.Domain, scope.Key(alias), interstitial.Enabled, interstitial.Message)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUrlNotFound)
+	}
+
+	return nil
+}
+
+// CountVariantClick records a redirect to variant id.
+func (s *Storage) CountVariantClick(id int64) error {
+	const op = "storage.postgres.CountVariantClick"
+
+	ctx := context.Background()
+
+	if _, err := s.pool.Exec(ctx, `UPDATE link_variants SET clicks = clicks + 1 WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("%s: exec: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetSchedule replaces the destination schedule of the link stored under
+// alias. Entries must not share an effective time.
+func (s *Storage) SetSchedule(scope storage.Scope, alias string, entries []storage.ScheduleEntry) error {
+	const op = "storage.postgres.SetSchedule"
+
+	ctx := context.Background()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, `SELECT id FROM urls WHERE workspace_id = $1 AND domain = $2 AND alias = $3 FOR UPDATE`,
+		scope.WorkspaceID, scope.Domain, scope.Key(alias)).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrUrlNotFound
+		}
+		return fmt.Errorf("%s: select: %w", op, err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM link_schedule WHERE url_id = $1`, id); err != nil {
+		return fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	for _, e := range entries {
+		_, err := tx.Exec(ctx, `INSERT INTO link_schedule(url_id, effective_from, url) VALUES($1, $2, $3)`,
+			id, e.EffectiveFrom, e.URL)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+				return fmt.Errorf("%s: %w", op, storage.ErrScheduleOverlap)
+			}
+			return fmt.Errorf("%s: insert: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// SetTargets replaces the targeting rules of the link stored under
+// alias, keeping their order.
+func (s *Storage) SetTargets(scope storage.Scope, alias string, rules []storage.TargetRule) error {
+	const op = "storage.postgres.SetTargets"
+
+	ctx := context.Background()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	err = tx.QueryRow(ctx, `SELECT id FROM urls WHERE workspace_id = $1 AND domain = $2 AND alias = $3 FOR UPDATE`,
+		scope.WorkspaceID, scope.Domain, scope.Key(alias)).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrUrlNotFound
+		}
+		return fmt.Errorf("%s: select: %w", op, err)
+	}
+
+	if err := setTargets(ctx, tx, id, rules); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return nil
+}
+
+func setTargets(ctx context.Context, tx pgx.Tx, id int64, rules []storage.TargetRule) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM link_targets WHERE url_id = $1`, id); err != nil {
+		return fmt.Errorf("delete targets: %w", err)
+	}
+
+	for i, t := range rules {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO link_targets(url_id, position, os, devices, browsers, languages, countries, regions, url)
+			VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, id, i, nonNil(t.OS), nonNil(t.Devices), nonNil(t.Browsers), nonNil(t.Languages),
+			nonNil(t.Countries), nonNil(t.Regions), t.URL)
+		if err != nil {
+			return fmt.Errorf("insert target: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// nonNil keeps pgx from storing NULL for an empty list.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
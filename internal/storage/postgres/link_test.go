@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"URL-Shortener/internal/config"
+	"URL-Shortener/internal/storage"
+	"context"
+	"fmt"
+	"github.com/ilyakaznacheev/cleanenv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestStorage connects to the database named by the PG* environment
+// variables and skips the test if they are not set. Deleted aliases are
+// tombstoned for an hour.
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	var db config.PostgresDB
+	if err := cleanenv.ReadEnv(&db); err != nil {
+		t.Skipf("postgres not configured: %v", err)
+	}
+
+	cfg := &config.Config{PostgresDB: db}
+	cfg.AliasTombstoneTTL = time.Hour
+	s, err := NewStorage(db.ConnString(), cfg)
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+var workspaceSeq atomic.Int64
+
+// newTestWorkspace creates a workspace with the short domains hosts that
+// is deleted, along with its domains and links, when the test ends.
+func newTestWorkspace(t *testing.T, s *Storage, hosts ...string) storage.Workspace {
+	t.Helper()
+
+	slug := fmt.Sprintf("test-%d-%d", time.Now().UnixNano(), workspaceSeq.Add(1))
+	ws, err := s.CreateWorkspace(storage.Workspace{Name: slug, Slug: slug}, hosts)
+	if err != nil {
+		t.Fatalf("CreateWorkspace: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := s.pool.Exec(context.Background(), `DELETE FROM workspaces WHERE id = $1`, ws.ID); err != nil {
+			t.Errorf("delete workspace: %v", err)
+		}
+	})
+	return ws
+}
+
+func TestTakeClickConcurrent(t *testing.T) {
+	s := newTestStorage(t)
+	ws := newTestWorkspace(t, s)
+	scope := storage.Scope{WorkspaceID: ws.ID}
+
+	const visitors = 50
+
+	for _, maxClicks := range []int{1, 5} {
+		t.Run(fmt.Sprintf("max_clicks=%d", maxClicks), func(t *testing.T) {
+			alias := fmt.Sprintf("limited%d", maxClicks)
+			link := storage.Link{Alias: alias, URL: "https://example.com", MaxClicks: maxClicks}
+			if _, err := s.SaveURL(scope, link); err != nil {
+				t.Fatalf("SaveURL: %v", err)
+			}
+
+			var (
+				wg    sync.WaitGroup
+				taken atomic.Int32
+				start = make(chan struct{})
+			)
+			for range visitors {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					ok, err := s.TakeClick(scope, alias)
+					if err != nil {
+						t.Errorf("TakeClick: %v", err)
+						return
+					}
+					if ok {
+						taken.Add(1)
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			if got := int(taken.Load()); got != maxClicks {
+				t.Errorf("%d of %d concurrent clicks taken, want %d", got, visitors, maxClicks)
+			}
+
+			got, err := s.GetLink(scope, alias)
+			if err != nil {
+				t.Fatalf("GetLink: %v", err)
+			}
+			if got.ClicksLeft != 0 {
+				t.Errorf("ClicksLeft = %d, want 0", got.ClicksLeft)
+			}
+		})
+	}
+}
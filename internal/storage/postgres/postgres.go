@@ -4,16 +4,22 @@ import (
 	"URL-Shortener/internal/config"
 	"URL-Shortener/internal/storage"
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	"strings"
+	"time"
 )
 
 type Storage struct {
 	pool *pgxpool.Pool
+	// caseInsensitive applies case-insensitive aliases to every workspace.
+	caseInsensitive bool
+	// tombstoneTTL is how long deleted aliases stay unavailable.
+	tombstoneTTL time.Duration
 }
 
 func NewStorage(conn string, cfg *config.Config) (*Storage, error) {
@@ -51,7 +57,159 @@ func NewStorage(conn string, cfg *config.Config) (*Storage, error) {
 		fmt.Printf("%s Error: %s\n", op, err)
 	}
 
-	return &Storage{pool: pool}, nil
+	if err := migrate(ctx, pool); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{
+		pool:            pool,
+		caseInsensitive: cfg.CaseInsensitiveAliases,
+		tombstoneTTL:    cfg.AliasTombstoneTTL,
+	}, nil
+}
+
+// migrate brings an existing urls table up to date with the columns,
+// indexes and triggers added after the initial schema.
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	const op = "storage.postgres.migrate"
+
+	statements := []string{
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS search TSVECTOR`,
+		`CREATE OR REPLACE FUNCTION urls_search_update() RETURNS trigger AS $$
+		BEGIN
+			NEW.search :=
+				setweight(to_tsvector('simple', coalesce(NEW.alias, '')), 'A') ||
+				setweight(to_tsvector('simple', coalesce(NEW.title, '')), 'A') ||
+				setweight(to_tsvector('simple', array_to_string(NEW.tags, ' ')), 'B') ||
+				setweight(to_tsvector('simple', coalesce(NEW.notes, '')), 'C') ||
+				setweight(to_tsvector('simple', coalesce(NEW.url, '')), 'D');
+			RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS urls_search_trigger ON urls`,
+		`CREATE TRIGGER urls_search_trigger BEFORE INSERT OR UPDATE ON urls
+			FOR EACH ROW EXECUTE FUNCTION urls_search_update()`,
+		`UPDATE urls SET alias = alias WHERE search IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_search ON urls USING GIN (search)`,
+		`CREATE TABLE IF NOT EXISTS workspaces (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			slug TEXT UNIQUE NOT NULL,
+			alias_length INT NOT NULL DEFAULT 0,
+			redirect_code INT NOT NULL DEFAULT 302,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+		`INSERT INTO workspaces(name, slug) VALUES('Default', 'default') ON CONFLICT (slug) DO NOTHING`,
+		`CREATE TABLE IF NOT EXISTS workspace_members (
+			workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			email TEXT NOT NULL,
+			role TEXT NOT NULL,
+			PRIMARY KEY (workspace_id, email)
+		)`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id BIGSERIAL PRIMARY KEY,
+			workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			name TEXT NOT NULL DEFAULT '',
+			key_hash TEXT UNIQUE NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+		`CREATE TABLE IF NOT EXISTS domains (
+			host TEXT PRIMARY KEY,
+			workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE
+		)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS workspace_id BIGINT REFERENCES workspaces(id) ON DELETE CASCADE`,
+		`UPDATE urls SET workspace_id = (SELECT id FROM workspaces WHERE slug = 'default') WHERE workspace_id IS NULL`,
+		`ALTER TABLE urls ALTER COLUMN workspace_id SET NOT NULL`,
+		`ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_alias_key`,
+		`ALTER TABLE domains ADD COLUMN IF NOT EXISTS fallback_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE domains ADD COLUMN IF NOT EXISTS base_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT ''`,
+		`DROP INDEX IF EXISTS idx_urls_workspace_alias`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_workspace_domain_alias ON urls(workspace_id, domain, alias)`,
+		`ALTER TABLE workspaces ADD COLUMN IF NOT EXISTS case_insensitive BOOLEAN NOT NULL DEFAULT false`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_alias_prefix ON urls(workspace_id, domain, alias text_pattern_ops)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS passthrough_path BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS passthrough_query TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS passthrough_fragment TEXT NOT NULL DEFAULT ''`,
+		`CREATE TABLE IF NOT EXISTS alias_tombstones (
+			workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			domain TEXT NOT NULL,
+			alias TEXT NOT NULL,
+			deleted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (workspace_id, domain, alias)
+		)`,
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_alias_trgm ON urls USING GIN (alias gin_trgm_ops)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS state TEXT NOT NULL DEFAULT 'active'`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS active_from TIMESTAMPTZ`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS active_until TIMESTAMPTZ`,
+		`ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_active_window_check`,
+		`ALTER TABLE urls ADD CONSTRAINT urls_active_window_check
+			CHECK (active_from IS NULL OR active_until IS NULL OR active_from < active_until)`,
+		`CREATE TABLE IF NOT EXISTS link_schedule (
+			url_id INT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			effective_from TIMESTAMPTZ NOT NULL,
+			url TEXT NOT NULL,
+			PRIMARY KEY (url_id, effective_from)
+		)`,
+		`CREATE TABLE IF NOT EXISTS link_variants (
+			id BIGSERIAL PRIMARY KEY,
+			url_id INT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			url TEXT NOT NULL,
+			weight INT NOT NULL CHECK (weight > 0),
+			clicks BIGINT NOT NULL DEFAULT 0,
+			UNIQUE (url_id, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS link_targets (
+			url_id INT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			os TEXT[] NOT NULL DEFAULT '{}',
+			devices TEXT[] NOT NULL DEFAULT '{}',
+			browsers TEXT[] NOT NULL DEFAULT '{}',
+			languages TEXT[] NOT NULL DEFAULT '{}',
+			url TEXT NOT NULL,
+			PRIMARY KEY (url_id, position)
+		)`,
+		`ALTER TABLE link_targets ADD COLUMN IF NOT EXISTS countries TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE link_targets ADD COLUMN IF NOT EXISTS regions TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS app_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS app_fallback_ios TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS app_fallback_android TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS max_clicks INT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS clicks_left INT`,
+		`ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_clicks_left_check`,
+		`ALTER TABLE urls ADD CONSTRAINT urls_clicks_left_check
+			CHECK (clicks_left IS NULL OR (clicks_left >= 0 AND clicks_left <= max_clicks))`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS interstitial BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS interstitial_message TEXT NOT NULL DEFAULT ''`,
+		// Added without a default first so that existing links are not
+		// all dated to the migration.
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ`,
+		`ALTER TABLE urls ALTER COLUMN created_at SET DEFAULT now()`,
+		// Workspaces made case-insensitive before they got their index, see
+		// createLowerAliasIndex.
+		`DO $$
+		DECLARE ws BIGINT;
+		BEGIN
+			FOR ws IN SELECT id FROM workspaces WHERE case_insensitive LOOP
+				EXECUTE format('CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_lower_alias_ws_%s ON urls(domain, lower(alias)) WHERE workspace_id = %s', ws, ws);
+			END LOOP;
+		END $$`,
+	}
+	statements = append(statements, foldTombstones(`workspace_id IN (SELECT id FROM workspaces WHERE case_insensitive)`)...)
+
+	for _, stmt := range statements {
+		if _, err := pool.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
 }
 
 func (s *Storage) Close() {
@@ -60,46 +218,166 @@ func (s *Storage) Close() {
 	}
 }
 
-func (s *Storage) SaveURL(alias, urlToSave string) (int64, error) {
+// liveTombstone selects the tombstone of alias $3 on workspace $1 and
+// domain $2 if it was deleted less than $11 seconds ago.
+const liveTombstone = `
+	SELECT 1 FROM alias_tombstones t
+	WHERE t.workspace_id = $1 AND t.domain = $2 AND t.alias = $3
+		AND t.deleted_at > now() - make_interval(secs => $11)`
+
+func (s *Storage) SaveURL(scope storage.Scope, link storage.Link) (int64, error) {
 	const op = "storage.postgres.SaveURL"
 
 	ctx := context.Background()
-	query := `INSERT INTO url(alias, url) VALUES($1, $2) RETURNING id`
+	query := `
+		INSERT INTO urls(workspace_id, domain, alias, url, title, notes, tags,
+			passthrough_path, passthrough_query, passthrough_fragment, state, active_from, active_until,
+			app_url, app_fallback_ios, app_fallback_android, password_hash, max_clicks, clicks_left)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $12, $13::timestamptz, $14::timestamptz, $15, $16, $17, $18,
+			NULLIF($19::int, 0), NULLIF($19::int, 0)
+		WHERE NOT EXISTS (` + liveTombstone + `)
+		RETURNING id
+	`
+
+	tags := link.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	state := link.State
+	if state == "" {
+		state = storage.StateActive
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
 	var id int64
-	err := s.pool.QueryRow(ctx, query, alias, urlToSave).Scan(&id)
+	err = tx.QueryRow(ctx, query, scope.WorkspaceID, scope.Domain, scope.Key(link.Alias), link.URL, link.Title, link.Notes, tags,
+		link.Passthrough.Path, link.Passthrough.Query, link.Passthrough.Fragment, s.tombstoneTTL.Seconds(), state,
+		link.ActiveFrom, link.ActiveUntil, link.App.URL, link.App.IOSFallback, link.App.AndroidFallback,
+		link.PasswordHash, link.MaxClicks).Scan(&id)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAliasTombstoned)
+		}
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrAliasExists)
 		}
+		if errors.As(err, &pgErr) && pgErr.Code == "23514" { // check_violation
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrInvalidWindow)
+		}
 		return 0, fmt.Errorf("%s: exec: %w", op, err)
 	}
 
+	if len(link.Variants) > 0 {
+		if err := setVariants(ctx, tx, id, link.Variants); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if len(link.Targets) > 0 {
+		if err := setTargets(ctx, tx, id, link.Targets); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
 	return id, nil
 }
 
-func (s *Storage) GetUrl(alias string) (string, error) {
-	const op = "storage.postgres.GetUrl"
+// SimilarAliases returns up to limit aliases of scope that start with
+// query or are trigram-similar to it, prefix matches first. Callers
+// rerank them by edit distance; the pg_trgm index keeps this from
+// scanning the urls table. With public, links whose existence anonymous
+// visitors must not learn of are left out: password-protected,
+// click-limited and reserved ones.
+func (s *Storage) SimilarAliases(scope storage.Scope, query string, limit int, public bool) ([]string, error) {
+	const op = "storage.postgres.SimilarAliases"
+
+	ctx := context.Background()
+
+	query = scope.Key(query)
+	rows, err := s.pool.Query(ctx, `
+		SELECT alias FROM urls
+		WHERE workspace_id = $1 AND domain = $2 AND (alias LIKE $4 OR alias % $3)
+			AND (NOT $6 OR (password_hash = '' AND max_clicks IS NULL AND state <> $7))
+		ORDER BY (alias LIKE $4) DESC, similarity(alias, $3) DESC, alias
+		LIMIT $5
+	`, scope.WorkspaceID, scope.Domain, query, likePrefix(query), limit, public, storage.StateReserved)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var aliases []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		aliases = append(aliases, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return aliases, nil
+}
+
+// ListURLs pages through the links of scope, optionally restricted to
+// aliases starting with prefix, e.g. "eng/" for every link under /eng/.
+func (s *Storage) ListURLs(scope storage.Scope, prefix string, limit int, offset int) ([]storage.Link, error) {
+	const op = "storage.postgres.ListURLs"
 
 	ctx := context.Background()
-	var url string
-	err := s.pool.QueryRow(ctx, `SELECT url FROM urls WHERE alias = $1`, alias).Scan(&url)
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT domain, alias, url, title, notes, tags FROM urls
+		WHERE workspace_id = $1 AND domain = $2 AND alias LIKE $3
+		ORDER BY alias
+		LIMIT $4 OFFSET $5
+	`, scope.WorkspaceID, scope.Domain, likePrefix(scope.Key(prefix)), limit, offset)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", storage.ErrUrlNotFound
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var links []storage.Link
+	for rows.Next() {
+		var link storage.Link
+		if err := rows.Scan(&link.Domain, &link.Alias, &link.URL, &link.Title, &link.Notes, &link.Tags); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
 		}
-		return "", fmt.Errorf("%s: query: %w", op, err)
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
 	}
 
-	return url, nil
+	return links, nil
 }
 
-func (s *Storage) DeleteUrl(alias string) error {
+// DeleteUrl removes a link and, when a tombstone TTL is configured,
+// records its alias so that it cannot be reused right away.
+func (s *Storage) DeleteUrl(scope storage.Scope, alias string) error {
 	const op = "storage.postgres.DeleteUrl"
 
 	ctx := context.Background()
 
-	result, err := s.pool.Exec(ctx, `DELETE FROM urls  WHERE alias = $1`, alias)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM urls WHERE workspace_id = $1 AND domain = $2 AND alias = $3`,
+		scope.WorkspaceID, scope.Domain, scope.Key(alias))
 	if err != nil {
 		return fmt.Errorf("%s: exec: %w", op, err)
 	}
@@ -109,21 +387,282 @@ func (s *Storage) DeleteUrl(alias string) error {
 		return storage.ErrUrlNotFound
 	}
 
+	if s.tombstoneTTL > 0 {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO alias_tombstones(workspace_id, domain, alias) VALUES($1, $2, $3)
+			ON CONFLICT (workspace_id, domain, alias) DO UPDATE SET deleted_at = now()
+		`, scope.WorkspaceID, scope.Domain, scope.Key(alias))
+		if err != nil {
+			return fmt.Errorf("%s: tombstone: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
 	return nil
 }
 
-func (s *Storage) AliasExists(alias string) (bool, error) {
+// AliasExists reports whether alias is taken in scope, either by a link
+// or by the tombstone of a recently deleted one.
+func (s *Storage) AliasExists(scope storage.Scope, alias string) (bool, error) {
 	const op = "storage.postgres.AliasExists"
 
+	taken, err := s.TakenAliases(scope, []string{alias})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return len(taken) > 0, nil
+}
+
+// TakenAliases returns the aliases among aliases that are not available
+// in scope, together with the reason: storage.ErrAliasExists or
+// storage.ErrAliasTombstoned.
+func (s *Storage) TakenAliases(scope storage.Scope, aliases []string) (map[string]error, error) {
+	const op = "storage.postgres.TakenAliases"
+
 	ctx := context.Background()
 
-	var exists bool
-	err := s.pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM urls WHERE alias = $1)`, alias).Scan(&exists)
+	keys := make([]string, 0, len(aliases))
+	byKey := make(map[string]string, len(aliases))
+	for _, a := range aliases {
+		keys = append(keys, scope.Key(a))
+		byKey[scope.Key(a)] = a
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT alias, false FROM urls
+		WHERE workspace_id = $1 AND domain = $2 AND alias = ANY($3)
+		UNION ALL
+		SELECT alias, true FROM alias_tombstones
+		WHERE workspace_id = $1 AND domain = $2 AND alias = ANY($3)
+			AND deleted_at > now() - make_interval(secs => $4)
+	`, scope.WorkspaceID, scope.Domain, keys, s.tombstoneTTL.Seconds())
 	if err != nil {
-		return false, fmt.Errorf("%s: query: %w", op, err)
+		return nil, fmt.Errorf("%s: query: %w", op, err)
 	}
+	defer rows.Close()
+
+	taken := make(map[string]error)
+	for rows.Next() {
+		var key string
+		var tombstoned bool
+		if err := rows.Scan(&key, &tombstoned); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		reason := storage.ErrAliasExists
+		if tombstoned {
+			reason = storage.ErrAliasTombstoned
+		}
+		if _, ok := taken[byKey[key]]; !ok || !tombstoned {
+			taken[byKey[key]] = reason
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return taken, nil
+}
+
+// FindAliases returns every stored alias that case-insensitively matches
+// one of aliases, in any workspace or domain.
+func (s *Storage) FindAliases(aliases []string) ([]storage.AliasRef, error) {
+	const op = "storage.postgres.FindAliases"
+
+	ctx := context.Background()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT workspace_id, domain, alias FROM urls
+		WHERE lower(alias) = ANY($1)
+		ORDER BY workspace_id, domain, alias
+	`, aliases)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var refs []storage.AliasRef
+	for rows.Next() {
+		var ref storage.AliasRef
+		if err := rows.Scan(&ref.WorkspaceID, &ref.Domain, &ref.Alias); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return refs, nil
+}
+
+// FilterAliases returns the stored aliases keep reports true for, in any
+// workspace or domain. keep is given each alias along with the alias
+// length of its workspace, zero for the deployment default, and whether
+// the workspace is case-insensitive.
+func (s *Storage) FilterAliases(keep func(alias string, aliasLength int, caseInsensitive bool) bool) ([]storage.AliasRef, error) {
+	const op = "storage.postgres.FilterAliases"
+
+	ctx := context.Background()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT u.workspace_id, u.domain, u.alias, w.alias_length, w.case_insensitive
+		FROM urls u JOIN workspaces w ON w.id = u.workspace_id
+		ORDER BY u.workspace_id, u.domain, u.alias
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var refs []storage.AliasRef
+	for rows.Next() {
+		var ref storage.AliasRef
+		var aliasLength int
+		var caseInsensitive bool
+		if err := rows.Scan(&ref.WorkspaceID, &ref.Domain, &ref.Alias, &aliasLength, &caseInsensitive); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		if keep(ref.Alias, aliasLength, caseInsensitive || s.caseInsensitive) {
+			refs = append(refs, ref)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return refs, nil
+}
+
+// CaseCollisions returns the aliases that only differ in case from
+// another alias on the same workspace and domain, i.e. those that block a
+// switch to case-insensitive aliases. A zero workspaceID checks every
+// workspace.
+func (s *Storage) CaseCollisions(workspaceID int64) ([]storage.AliasRef, error) {
+	const op = "storage.postgres.CaseCollisions"
+
+	ctx := context.Background()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT workspace_id, domain, alias FROM (
+			SELECT workspace_id, domain, alias,
+				count(*) OVER (PARTITION BY workspace_id, domain, lower(alias)) AS n
+			FROM urls
+			WHERE $1::bigint = 0 OR workspace_id = $1
+		) c
+		WHERE n > 1
+		ORDER BY workspace_id, domain, lower(alias), alias
+	`, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var refs []storage.AliasRef
+	for rows.Next() {
+		var ref storage.AliasRef
+		if err := rows.Scan(&ref.WorkspaceID, &ref.Domain, &ref.Alias); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return refs, nil
+}
+
+// FoldAliases migrates every stored alias and tombstone to its lower-case
+// canonical form and enforces uniqueness on lower(alias). It is run when
+// case-insensitive aliases are enabled for the whole deployment and
+// fails with ErrCaseCollision while CaseCollisions reports anything.
+func (s *Storage) FoldAliases() error {
+	const op = "storage.postgres.FoldAliases"
+
+	ctx := context.Background()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	statements := append([]string{
+		`UPDATE urls SET alias = lower(alias) WHERE alias <> lower(alias)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_workspace_domain_lower_alias ON urls(workspace_id, domain, lower(alias))`,
+	}, foldTombstones(`true`)...)
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+				return fmt.Errorf("%s: %w", op, storage.ErrCaseCollision)
+			}
+			return fmt.Errorf("%s: exec: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) Search(scope storage.Scope, query string, limit int) ([]storage.SearchResult, error) {
+	const op = "storage.postgres.Search"
+
+	ctx := context.Background()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT domain, alias, url, title, notes, tags,
+			ts_rank_cd(search, q) AS rank,
+			ts_headline('simple',
+				`+escapeHTML(`concat_ws(' ', title, notes, array_to_string(tags, ' '), url)`)+`, q,
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5'
+			) AS snippet
+		FROM urls, websearch_to_tsquery('simple', $2) AS q
+		WHERE workspace_id = $1 AND (search @@ q OR alias ILIKE $3)
+		ORDER BY (lower(alias) = lower($2)) DESC, rank DESC, id DESC
+		LIMIT $4
+	`, scope.WorkspaceID, query, likePrefix(query), limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var results []storage.SearchResult
+	for rows.Next() {
+		var res storage.SearchResult
+		if err := rows.Scan(&res.Domain, &res.Alias, &res.URL, &res.Title, &res.Notes, &res.Tags, &res.Rank, &res.Snippet); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return results, nil
+}
+
+// escapeHTML wraps the SQL text expression expr so that it yields
+// HTML-escaped text. Snippets are cut from the escaped text, which leaves
+// the <mark> tags of ts_headline as their only markup.
+func escapeHTML(expr string) string {
+	for _, r := range [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&quot;"}, {"''", "&#39;"}} {
+		expr = fmt.Sprintf("replace(%s, '%s', '%s')", expr, r[0], r[1])
+	}
+	return expr
+}
 
-	return exists, nil
+// likePrefix escapes LIKE wildcards in s and turns it into a prefix pattern.
+func likePrefix(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s) + "%"
 }
 
 func (s *Storage) GetPoolStats() *pgxpool.Stat {
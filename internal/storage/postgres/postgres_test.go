@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"URL-Shortener/internal/storage"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestScopeIsolation stores the same alias in two workspaces, each on
+// its own short domain and on the default one, and checks that no query
+// reaches across workspaces or domains.
+func TestScopeIsolation(t *testing.T) {
+	s := newTestStorage(t)
+
+	suffix := fmt.Sprintf("%d.test", time.Now().UnixNano())
+	acmeHost, globexHost := "acme-"+suffix, "globex-"+suffix
+	acme := newTestWorkspace(t, s, acmeHost)
+	globex := newTestWorkspace(t, s, globexHost)
+
+	scopes := map[string]storage.Scope{
+		"acme":          {WorkspaceID: acme.ID},
+		"acme domain":   {WorkspaceID: acme.ID, Domain: acmeHost},
+		"globex":        {WorkspaceID: globex.ID},
+		"globex domain": {WorkspaceID: globex.ID, Domain: globexHost},
+	}
+	dest := func(name string) string {
+		return "https://example.com/" + strings.ReplaceAll(name, " ", "-")
+	}
+	for name, scope := range scopes {
+		link := storage.Link{Alias: "shared", URL: dest(name), Title: "shared " + name}
+		if _, err := s.SaveURL(scope, link); err != nil {
+			t.Fatalf("SaveURL(%s): %v", name, err)
+		}
+	}
+	only := scopes["acme domain"]
+	if _, err := s.SaveURL(only, storage.Link{Alias: "only-acme", URL: dest("only")}); err != nil {
+		t.Fatalf("SaveURL(only-acme): %v", err)
+	}
+
+	for name, scope := range scopes {
+		t.Run(name, func(t *testing.T) {
+			link, err := s.GetLink(scope, "shared")
+			if err != nil || link.URL != dest(name) {
+				t.Errorf("GetLink = %q, %v; want %q", link.URL, err, dest(name))
+			}
+
+			link, err = s.MatchLink(scope, "shared")
+			if err != nil || link.URL != dest(name) {
+				t.Errorf("MatchLink = %q, %v; want %q", link.URL, err, dest(name))
+			}
+
+			links, err := s.ListURLs(scope, "", 10, 0)
+			if err != nil {
+				t.Fatalf("ListURLs: %v", err)
+			}
+			for _, l := range links {
+				if l.URL != dest(name) && !(scope == only && l.Alias == "only-acme") {
+					t.Errorf("ListURLs returned %s -> %s", l.Alias, l.URL)
+				}
+			}
+
+			aliases, err := s.SimilarAliases(scope, "shar", 10, false)
+			if err != nil || len(aliases) != 1 {
+				t.Errorf("SimilarAliases = %v, %v; want [shared]", aliases, err)
+			}
+
+			// Search covers every domain of the workspace.
+			results, err := s.Search(scope, "shared", 10)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(results) != 2 {
+				t.Errorf("Search returned %d results, want 2", len(results))
+			}
+			for _, res := range results {
+				owner := scopes[strings.TrimPrefix(res.Title, "shared ")]
+				if owner.WorkspaceID != scope.WorkspaceID {
+					t.Errorf("Search returned %s of another workspace", res.URL)
+				}
+			}
+
+			taken, err := s.TakenAliases(scope, []string{"shared", "only-acme"})
+			if err != nil {
+				t.Fatalf("TakenAliases: %v", err)
+			}
+			_, onlyTaken := taken["only-acme"]
+			if !errors.Is(taken["shared"], storage.ErrAliasExists) || onlyTaken != (scope == only) {
+				t.Errorf("TakenAliases = %v", taken)
+			}
+		})
+	}
+
+	t.Run("find", func(t *testing.T) {
+		refs, err := s.FindAliases([]string{"only-acme"})
+		if err != nil {
+			t.Fatalf("FindAliases: %v", err)
+		}
+		want := storage.AliasRef{WorkspaceID: acme.ID, Domain: acmeHost, Alias: "only-acme"}
+		if !slices.Contains(refs, want) {
+			t.Errorf("FindAliases = %v, want %v among them", refs, want)
+		}
+		for _, ref := range refs {
+			if ref.WorkspaceID == globex.ID {
+				t.Errorf("FindAliases returned %v", ref)
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		for name, scope := range scopes {
+			if scope == only {
+				continue
+			}
+			if err := s.DeleteUrl(scope, "only-acme"); !errors.Is(err, storage.ErrUrlNotFound) {
+				t.Errorf("DeleteUrl(%s) = %v, want ErrUrlNotFound", name, err)
+			}
+		}
+		if _, err := s.GetLink(only, "only-acme"); err != nil {
+			t.Fatalf("only-acme is gone after deleting it elsewhere: %v", err)
+		}
+
+		if err := s.DeleteUrl(scopes["acme"], "shared"); err != nil {
+			t.Fatalf("DeleteUrl: %v", err)
+		}
+		for name, scope := range scopes {
+			if name == "acme" {
+				continue
+			}
+			if _, err := s.GetLink(scope, "shared"); err != nil {
+				t.Errorf("GetLink(%s) after deleting acme's: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("tombstones", func(t *testing.T) {
+		if err := s.DeleteUrl(only, "only-acme"); err != nil {
+			t.Fatalf("DeleteUrl: %v", err)
+		}
+		link := storage.Link{Alias: "only-acme", URL: dest("reused")}
+		if _, err := s.SaveURL(only, link); !errors.Is(err, storage.ErrAliasTombstoned) {
+			t.Errorf("SaveURL in the same scope = %v, want ErrAliasTombstoned", err)
+		}
+		for name, scope := range scopes {
+			if scope == only {
+				continue
+			}
+			taken, err := s.TakenAliases(scope, []string{"only-acme"})
+			if err != nil || len(taken) > 0 {
+				t.Errorf("TakenAliases(%s) = %v, %v; want none", name, taken, err)
+			}
+			if _, err := s.SaveURL(scope, link); err != nil {
+				t.Errorf("SaveURL(%s) = %v; the tombstone is acme's", name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package postgres
+
+import (
+	"URL-Shortener/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const workspaceColumns = `w.id, w.name, w.slug, w.alias_length, w.redirect_code, w.case_insensitive`
+
+func (s *Storage) scanWorkspace(row pgx.Row) (storage.Workspace, error) {
+	var ws storage.Workspace
+	err := row.Scan(&ws.ID, &ws.Name, &ws.Slug, &ws.AliasLength, &ws.RedirectCode, &ws.CaseInsensitive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.Workspace{}, storage.ErrWorkspaceNotFound
+	}
+	ws.CaseInsensitive = ws.CaseInsensitive || s.caseInsensitive
+	return ws, err
+}
+
+func (s *Storage) CreateWorkspace(ws storage.Workspace, hosts []string) (storage.Workspace, error) {
+	const op = "storage.postgres.CreateWorkspace"
+
+	ctx := context.Background()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO workspaces(name, slug, alias_length, redirect_code)
+		VALUES($1, $2, $3, $4) RETURNING id
+	`, ws.Name, ws.Slug, ws.AliasLength, ws.RedirectCode).Scan(&ws.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return storage.Workspace{}, fmt.Errorf("%s: %w", op, storage.ErrWorkspaceExists)
+		}
+		return storage.Workspace{}, fmt.Errorf("%s: insert workspace: %w", op, err)
+	}
+
+	for _, host := range hosts {
+		_, err := tx.Exec(ctx, `INSERT INTO domains(host, workspace_id) VALUES($1, $2)`, host, ws.ID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+				return storage.Workspace{}, fmt.Errorf("%s: %w", op, storage.ErrDomainExists)
+			}
+			return storage.Workspace{}, fmt.Errorf("%s: insert domain: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return ws, nil
+}
+
+func (s *Storage) GetWorkspace(slug string) (storage.Workspace, error) {
+	const op = "storage.postgres.GetWorkspace"
+
+	ctx := context.Background()
+
+	row := s.pool.QueryRow(ctx, `SELECT `+workspaceColumns+` FROM workspaces w WHERE w.slug = $1`, slug)
+	ws, err := s.scanWorkspace(row)
+	if err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ws, nil
+}
+
+func (s *Storage) DefaultWorkspace() (storage.Workspace, error) {
+	return s.GetWorkspace(storage.DefaultWorkspaceSlug)
+}
+
+func (s *Storage) WorkspaceByAPIKey(keyHash string) (storage.Workspace, error) {
+	const op = "storage.postgres.WorkspaceByAPIKey"
+
+	ctx := context.Background()
+
+	row := s.pool.QueryRow(ctx, `
+		SELECT `+workspaceColumns+`
+		FROM api_keys k JOIN workspaces w ON w.id = k.workspace_id
+		WHERE k.key_hash = $1
+	`, keyHash)
+	ws, err := s.scanWorkspace(row)
+	if err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ws, nil
+}
+
+func (s *Storage) WorkspaceByID(id int64) (storage.Workspace, error) {
+	const op = "storage.postgres.WorkspaceByID"
+
+	ctx := context.Background()
+
+	row := s.pool.QueryRow(ctx, `SELECT `+workspaceColumns+` FROM workspaces w WHERE w.id = $1`, id)
+	ws, err := s.scanWorkspace(row)
+	if err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ws, nil
+}
+
+// UpdateWorkspaceSettings stores the alias length, redirect code and
+// case mode of a workspace. Switching to case-insensitive aliases folds
+// the existing ones and their tombstones to lower case and adds a unique
+// index on lower(alias) in the same transaction; callers should check
+// CaseCollisions first for a useful report.
+func (s *Storage) UpdateWorkspaceSettings(slug string, settings storage.Workspace) (storage.Workspace, error) {
+	const op = "storage.postgres.UpdateWorkspaceSettings"
+
+	ctx := context.Background()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		UPDATE workspaces w SET alias_length = $2, redirect_code = $3, case_insensitive = $4
+		WHERE w.slug = $1
+		RETURNING `+workspaceColumns, slug, settings.AliasLength, settings.RedirectCode, settings.CaseInsensitive)
+	ws, err := s.scanWorkspace(row)
+	if err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err := tx.Exec(ctx, `DROP INDEX IF EXISTS `+lowerAliasIndex(ws.ID)); err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: drop index: %w", op, err)
+	}
+
+	if ws.CaseInsensitive {
+		_, err := tx.Exec(ctx, `UPDATE urls SET alias = lower(alias) WHERE workspace_id = $1 AND alias <> lower(alias)`, ws.ID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+				return storage.Workspace{}, fmt.Errorf("%s: %w", op, storage.ErrCaseCollision)
+			}
+			return storage.Workspace{}, fmt.Errorf("%s: fold aliases: %w", op, err)
+		}
+		for _, stmt := range foldTombstones(`workspace_id = $1`) {
+			if _, err := tx.Exec(ctx, stmt, ws.ID); err != nil {
+				return storage.Workspace{}, fmt.Errorf("%s: fold tombstones: %w", op, err)
+			}
+		}
+		if _, err := tx.Exec(ctx, createLowerAliasIndex(ws.ID)); err != nil {
+			return storage.Workspace{}, fmt.Errorf("%s: create index: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return storage.Workspace{}, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return ws, nil
+}
+
+// lowerAliasIndex names the index that keeps the aliases of a
+// case-insensitive workspace unique regardless of case.
+func lowerAliasIndex(workspaceID int64) string {
+	return fmt.Sprintf("idx_urls_lower_alias_ws_%d", workspaceID)
+}
+
+func createLowerAliasIndex(workspaceID int64) string {
+	return fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %s ON urls(domain, lower(alias)) WHERE workspace_id = %d`,
+		lowerAliasIndex(workspaceID), workspaceID)
+}
+
+// foldTombstones returns the statements that move the tombstones matching
+// cond to lower-case aliases, keeping the latest deletion of those that
+// fold together, so that they keep blocking reuse.
+func foldTombstones(cond string) []string {
+	return []string{
+		`INSERT INTO alias_tombstones(workspace_id, domain, alias, deleted_at)
+			SELECT workspace_id, domain, lower(alias), max(deleted_at) FROM alias_tombstones
+			WHERE ` + cond + ` AND alias <> lower(alias)
+			GROUP BY workspace_id, domain, lower(alias)
+			ON CONFLICT (workspace_id, domain, alias)
+			DO UPDATE SET deleted_at = GREATEST(alias_tombstones.deleted_at, EXCLUDED.deleted_at)`,
+		`DELETE FROM alias_tombstones WHERE ` + cond + ` AND alias <> lower(alias)`,
+	}
+}
+
+func (s *Storage) CreateAPIKey(workspaceID int64, name string, keyHash string) error {
+	const op = "storage.postgres.CreateAPIKey"
+
+	ctx := context.Background()
+
+	_, err := s.pool.Exec(ctx, `INSERT INTO api_keys(workspace_id, name, key_hash) VALUES($1, $2, $3)`,
+		workspaceID, name, keyHash)
+	if err != nil {
+		return fmt.Errorf("%s: exec: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) ListMembers(workspaceID int64) ([]storage.Member, error) {
+	const op = "storage.postgres.ListMembers"
+
+	ctx := context.Background()
+
+	rows, err := s.pool.Query(ctx, `SELECT email, role FROM workspace_members WHERE workspace_id = $1 ORDER BY email`,
+		workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var members []storage.Member
+	for rows.Next() {
+		var m storage.Member
+		if err := rows.Scan(&m.Email, &m.Role); err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return members, nil
+}
+
+func (s *Storage) AddMember(workspaceID int64, member storage.Member) error {
+	const op = "storage.postgres.AddMember"
+
+	ctx := context.Background()
+
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO workspace_members(workspace_id, email, role) VALUES($1, $2, $3)
+		ON CONFLICT (workspace_id, email) DO UPDATE SET role = EXCLUDED.role
+	`, workspaceID, member.Email, member.Role)
+	if err != nil {
+		return fmt.Errorf("%s: exec: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) RemoveMember(workspaceID int64, email string) error {
+	const op = "storage.postgres.RemoveMember"
+
+	ctx := context.Background()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM workspace_members WHERE workspace_id = $1 AND email = $2`,
+		workspaceID, email)
+	if err != nil {
+		return fmt.Errorf("%s: exec: %w", op, err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return storage.ErrMemberNotFound
+	}
+
+	return nil
+}
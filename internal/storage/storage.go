@@ -1,8 +1,247 @@
 package storage
 
-import "errors"
+import (
+	"errors"
+	"strings"
+	"time"
+)
 
 var (
 	ErrUrlNotFound = errors.New("url not found")
 	ErrAliasExists = errors.New("alias already exists")
+	// ErrAliasTombstoned is returned for an alias deleted less than the
+	// tombstone TTL ago, which cannot be reused yet.
+	ErrAliasTombstoned = errors.New("alias was recently deleted")
+	ErrInvalidWindow   = errors.New("activation window ends before it starts")
+	ErrScheduleOverlap = errors.New("schedule entries overlap")
+
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrWorkspaceExists   = errors.New("workspace already exists")
+	ErrMemberNotFound    = errors.New("member not found")
+	ErrDomainExists      = errors.New("domain already exists")
+	ErrDomainNotFound    = errors.New("domain not found")
+	ErrCaseCollision     = errors.New("aliases differ only in case")
+)
+
+// DefaultWorkspaceSlug names the workspace whose links are served on
+// hosts that are not bound to any workspace.
+const DefaultWorkspaceSlug = "default"
+
+// Scope identifies the alias namespace a storage call operates in.
+type Scope struct {
+	WorkspaceID int64
+	// Domain is the short domain links are bound to; empty means links
+	// served from any host that is not a registered domain.
+	Domain string
+	// CaseInsensitive makes aliases match regardless of case. They are
+	// then stored in their canonical, lower-case form.
+	CaseInsensitive bool
+}
+
+// Key returns the form alias is stored and looked up under in s.
+func (s Scope) Key(alias string) string {
+	if s.CaseInsensitive {
+		return strings.ToLower(alias)
+	}
+	return alias
+}
+
+type Domain struct {
+	Host        string
+	WorkspaceID int64
+	FallbackURL string
+	// BaseURL overrides the public URL short links on this domain are
+	// built from, e.g. when it is served under a path prefix.
+	BaseURL string
+}
+
+type Workspace struct {
+	ID              int64
+	Name            string
+	Slug            string
+	AliasLength     int
+	RedirectCode    int
+	CaseInsensitive bool
+}
+
+type Member struct {
+	Email string
+	Role  string
+}
+
+// AliasRef locates an alias across workspaces and domains.
+type AliasRef struct {
+	WorkspaceID int64
+	Domain      string
+	Alias       string
+}
+
+type Link struct {
+	Domain      string
+	Alias       string
+	URL         string
+	Title       string
+	Notes       string
+	Tags        []string
+	Passthrough Passthrough
+	// State is StateActive or StateReserved.
+	State string
+	// ActiveFrom and ActiveUntil bound when the link redirects, in UTC.
+	// Either may be nil for an open-ended window.
+	ActiveFrom  *time.Time
+	ActiveUntil *time.Time
+	// Schedule switches the destination at set times, ordered by
+	// EffectiveFrom. URL applies until the first entry takes effect.
+	Schedule []ScheduleEntry
+	// Variants split traffic between destinations by weight while no
+	// schedule entry is in effect. URL is not used when there are any.
+	Variants []Variant
+	// Targets send matching clients elsewhere before any of the above is
+	// considered; the first matching rule wins.
+	Targets []TargetRule
+	// App opens the link in a mobile app when it is installed.
+	App AppLink
+	// PasswordHash, if set, is the bcrypt hash of the password visitors
+	// must give before they are redirected.
+	PasswordHash string
+	// MaxClicks, if positive, is how many redirects the link serves
+	// before it is gone; ClicksLeft of them remain.
+	MaxClicks  int
+	ClicksLeft int
+	// Interstitial shows visitors a warning page before they continue to
+	// the destination. It is set by admins.
+	Interstitial Interstitial
+	// CreatedAt is nil for links created before it was recorded.
+	CreatedAt *time.Time
+}
+
+type Interstitial struct {
+	Enabled bool
+	// Message is shown on the page; a generic warning is used if empty.
+	Message string
+}
+
+// AppLink sends mobile visitors through a bridge page that tries to open
+// URL, a custom scheme or universal link such as myapp://product/42, and
+// falls back to their platform's fallback (e.g. the store page) or the
+// link's regular destination if the app does not open. An empty URL
+// disables it.
+type AppLink struct {
+	URL             string
+	IOSFallback     string
+	AndroidFallback string
+}
+
+// TargetRule matches clients by User-Agent classification, preferred
+// language and location; empty lists match anything. Values are those of
+// package useragent, BCP 47 language tags, ISO 3166-1 alpha-2 countries
+// (or "EU" for the member states of the European Union) and ISO 3166-2
+// regions such as "US-CA".
+type TargetRule struct {
+	OS        []string
+	Devices   []string
+	Browsers  []string
+	Languages []string
+	Countries []string
+	Regions   []string
+	URL       string
+}
+
+type Variant struct {
+	ID     int64
+	Name   string
+	URL    string
+	Weight int
+	Clicks int64
+}
+
+type ScheduleEntry struct {
+	EffectiveFrom time.Time
+	URL           string
+}
+
+// Destination returns the URL the link points to at t and, if the
+// answer changes later because of the schedule or the activation
+// window, when that happens. rotate reports that the link is to be
+// split between its variants instead.
+func (l Link) Destination(t time.Time) (dest string, next *time.Time, rotate bool) {
+	dest, rotate = l.URL, len(l.Variants) > 0
+	for i := range l.Schedule {
+		if l.Schedule[i].EffectiveFrom.After(t) {
+			next = &l.Schedule[i].EffectiveFrom
+			break
+		}
+		dest, rotate = l.Schedule[i].URL, false
+	}
+
+	if l.ActiveUntil != nil && l.ActiveUntil.After(t) && (next == nil || l.ActiveUntil.Before(*next)) {
+		next = l.ActiveUntil
+	}
+	return dest, next, rotate
+}
+
+// Window reports where t falls relative to the link's activation window:
+// -1 before it, 0 inside and 1 after it.
+func (l Link) Window(t time.Time) int {
+	switch {
+	case l.ActiveFrom != nil && t.Before(*l.ActiveFrom):
+		return -1
+	case l.ActiveUntil != nil && !t.Before(*l.ActiveUntil):
+		return 1
+	default:
+		return 0
+	}
+}
+
+const (
+	StateActive = "active"
+	// StateReserved marks an alias claimed before its destination is
+	// known. It has no URL and does not redirect until it is updated.
+	StateReserved = "reserved"
 )
+
+// LinkPatch lists the attributes of a link to change; nil fields are
+// left as they are. Setting URL activates a reserved link.
+type LinkPatch struct {
+	URL         *string
+	Title       *string
+	Notes       *string
+	Tags        []string
+	Passthrough *Passthrough
+	ActiveFrom  *TimePatch
+	ActiveUntil *TimePatch
+	// Variants, if not nil, replaces the variants. Click counts are kept
+	// for variants whose name stays.
+	Variants *[]Variant
+	App      *AppLink
+	// PasswordHash replaces the password; an empty one removes it.
+	PasswordHash *string
+	// MaxClicks sets a new click limit, starting the count over; zero
+	// removes it.
+	MaxClicks *int
+}
+
+// TimePatch replaces a nullable time; a nil Time clears it.
+type TimePatch struct {
+	Time *time.Time
+}
+
+// Passthrough controls how the parts of a request that follow a link's
+// alias are carried over to its destination.
+type Passthrough struct {
+	// Path appends any path segments after the alias to the destination
+	// path, so /gh/repo/issues on a link /gh leads to <url>/repo/issues.
+	Path bool
+	// Query is one of the passthrough.Query* modes.
+	Query string
+	// Fragment is one of the passthrough.Fragment* modes.
+	Fragment string
+}
+
+type SearchResult struct {
+	Link
+	Rank float32
+	// Snippet is HTML: the matched text is escaped and the matches are
+	// wrapped in <mark> tags.
+	Snippet string
+}